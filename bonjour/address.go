@@ -0,0 +1,155 @@
+package bonjour
+
+import (
+	"net"
+	"sort"
+)
+
+const (
+	maxAddresses = 16
+)
+
+// localAddresses enumerates the unicast addresses of the configured
+// interfaces, or of all of them, with globally routable ones first.
+func localAddresses(cfg *Config) []net.IP {
+	interfaces := cfg.Interfaces
+	if len(interfaces) == 0 {
+		interfaces, _ = net.Interfaces()
+	}
+
+	var addrs []net.IP
+	for _, ifi := range interfaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		ifaddrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range ifaddrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && advertisable(ipnet.IP) {
+				addrs = append(addrs, ipnet.IP)
+			}
+		}
+	}
+
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return !addrs[i].IsLinkLocalUnicast() && addrs[j].IsLinkLocalUnicast()
+	})
+	if len(addrs) > maxAddresses {
+		addrs = addrs[:maxAddresses]
+	}
+	return addrs
+}
+
+func advertisable(ip net.IP) bool {
+	return ip != nil && !ip.IsUnspecified() && !ip.IsMulticast() && !ip.IsLoopback()
+}
+
+// localSubnets lists the subnets of the configured interfaces, or of all of
+// them.
+func localSubnets(cfg *Config) []*net.IPNet {
+	interfaces := cfg.Interfaces
+	if len(interfaces) == 0 {
+		interfaces, _ = net.Interfaces()
+	}
+
+	var subnets []*net.IPNet
+	for _, ifi := range interfaces {
+		if ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		ifaddrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range ifaddrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				subnets = append(subnets, ipnet)
+			}
+		}
+	}
+	return subnets
+}
+
+// onSubnet reports whether ip is link-local or lies on one of subnets.
+func onSubnet(subnets []*net.IPNet, ip net.IP) bool {
+	if ip.IsLinkLocalUnicast() {
+		return true
+	}
+	for _, subnet := range subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalAddress reports whether ip belongs to this machine.
+func isLocalAddress(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// announcedProviders turns the addresses carried by an announcement into
+// providers. Link-local IPv6 addresses only make sense on the link the
+// announcement arrived through and are dropped when that is unknown.
+func announcedProviders(addresses []string, addr *net.UDPAddr, port uint16) []Provider {
+	zone, zoned := addr.Zone, addr.Zone != ""
+	var providers []Provider
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil || ip.IsUnspecified() || ip.IsMulticast() {
+			continue
+		}
+
+		provider := Provider{
+			Host: ip.String(),
+			Port: port,
+		}
+		if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			if !zoned {
+				zone, zoned = interfaceOf(addr.IP), true
+			}
+			if zone == "" {
+				continue
+			}
+			provider.Zone = zone
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// interfaceOf names the interface on whose subnet ip lies, so announcements
+// heard over IPv4 resolve link-local addresses the same as over IPv6.
+func interfaceOf(ip net.IP) string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	for _, ifi := range interfaces {
+		ifaddrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range ifaddrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.Contains(ip) {
+				return ifi.Name
+			}
+		}
+	}
+	return ""
+}
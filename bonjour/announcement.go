@@ -0,0 +1,220 @@
+package bonjour
+
+import (
+	"errors"
+	"math"
+	"net"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+const (
+	announcementVersion = 4
+	legacyTagsSize      = 12
+	maxFieldSize        = 256
+	wildcardQuery       = "*"
+)
+
+var (
+	ErrMalformedAnnouncement = errors.New("Malformed Announcement")
+
+	legacyMetadataKeys = []string{
+		MetadataOS,
+		MetadataArch,
+		MetadataHost,
+		MetadataLang,
+	}
+)
+
+func validateService(service *Service) error {
+	if len(service.UUID) > maxFieldSize {
+		return util.ErrPayloadTooLarge
+	}
+	if len(service.Addresses) > maxAddresses {
+		return util.ErrPayloadTooLarge
+	}
+	for _, provider := range service.Providers() {
+		if len(provider.Host) > maxFieldSize || len(provider.Zone) > maxFieldSize {
+			return util.ErrPayloadTooLarge
+		}
+	}
+	for _, tag := range service.Tags {
+		if len(tag) > maxFieldSize {
+			return util.ErrPayloadTooLarge
+		}
+	}
+	for k, v := range service.Metadata {
+		if len(k) > maxFieldSize || len(v) > maxFieldSize {
+			return util.ErrPayloadTooLarge
+		}
+	}
+	return nil
+}
+
+func newAnnouncement(service *Service, namespace string, ttl time.Duration) *model.ServiceAnnouncement {
+	return &model.ServiceAnnouncement{
+		Uuid:      service.UUID,
+		Port:      int32(service.Provider.Port),
+		Tags:      service.Tags,
+		Version:   announcementVersion,
+		Metadata:  service.Metadata,
+		Namespace: namespace,
+		TtlMs:     uint32(ttl / time.Millisecond),
+		Addresses: serviceAddresses(service),
+	}
+}
+
+// Zones are meaningless to peers, which apply the one the announcement
+// arrives through instead.
+func serviceAddresses(service *Service) []string {
+	var addresses []string
+	for _, provider := range service.Providers() {
+		if provider.Host != "" {
+			addresses = append(addresses, provider.Host)
+		}
+	}
+	return addresses
+}
+
+// Services registered without a host are advertised under the configured
+// addresses.
+func marshalAnnouncement(service *Service, cfg *Config, ttl time.Duration) ([]byte, error) {
+	announcement := newAnnouncement(service, cfg.Namespace, ttl)
+	if len(announcement.Addresses) == 0 {
+		for _, ip := range cfg.Addresses {
+			announcement.Addresses = append(announcement.Addresses, ip.String())
+		}
+	}
+
+	data, err := proto.Marshal(announcement)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > datagramSize {
+		return nil, util.ErrPayloadTooLarge
+	}
+	return data, nil
+}
+
+func marshalQuery(uuid string, namespace string, unicastResponse bool) ([]byte, error) {
+	if uuid == "" {
+		uuid = wildcardQuery
+	}
+	if len(uuid) > maxFieldSize {
+		return nil, util.ErrPayloadTooLarge
+	}
+
+	query := &model.ServiceAnnouncement{
+		Version:         announcementVersion,
+		Namespace:       namespace,
+		Query:           uuid,
+		UnicastResponse: unicastResponse,
+	}
+	return proto.Marshal(query)
+}
+
+func unmarshalAnnouncement(data []byte) (*model.ServiceAnnouncement, error) {
+	if len(data) > datagramSize {
+		return nil, util.ErrPayloadTooLarge
+	}
+
+	announcement := &model.ServiceAnnouncement{}
+	if err := proto.Unmarshal(data, announcement); err != nil {
+		return nil, err
+	}
+	return announcement, nil
+}
+
+func serviceFromAnnouncement(addr *net.UDPAddr, announcement *model.ServiceAnnouncement) Service {
+	service := Service{
+		UUID: announcement.Uuid,
+		Provider: Provider{
+			Host: addr.IP.String(),
+			Zone: addr.Zone,
+			Port: uint16(announcement.Port),
+		},
+		Metadata: make(map[string]string),
+	}
+
+	if announcement.Version == 0 {
+		// Legacy announcements carry 12 tags followed by OS, Arch, Host and Lang.
+		tags := announcement.Tags
+		if len(tags) > legacyTagsSize {
+			for i, value := range tags[legacyTagsSize:] {
+				if i < len(legacyMetadataKeys) && value != "" {
+					service.Metadata[legacyMetadataKeys[i]] = value
+				}
+			}
+			tags = tags[:legacyTagsSize]
+		}
+		for _, tag := range tags {
+			if tag != "" {
+				service.Tags = append(service.Tags, tag)
+			}
+		}
+		return service
+	}
+
+	// Announced addresses win over the source of the packet, which depends on
+	// the route the sender picked.
+	if providers := announcedProviders(announcement.Addresses, addr, service.Provider.Port); len(providers) > 0 {
+		service.Provider = providers[0]
+		service.Addresses = providers[1:]
+	}
+
+	service.Tags = append(service.Tags, announcement.Tags...)
+	for k, v := range announcement.Metadata {
+		service.Metadata[k] = v
+	}
+	service.decodeNetwork()
+	return service
+}
+
+func parseAnnouncement(addr *net.UDPAddr, data []byte) (Service, *model.ServiceAnnouncement, error) {
+	announcement, err := unmarshalAnnouncement(data)
+	if err != nil {
+		return Service{}, nil, err
+	}
+
+	if addr == nil || addr.IP == nil {
+		return Service{}, nil, ErrMalformedAnnouncement
+	}
+	if announcement.Query != "" {
+		if announcement.Uuid != "" || len(announcement.Query) > maxFieldSize {
+			return Service{}, nil, ErrMalformedAnnouncement
+		}
+		return Service{}, announcement, nil
+	}
+
+	if announcement.Uuid == "" {
+		return Service{}, nil, ErrMalformedAnnouncement
+	}
+	if announcement.Port < 0 || announcement.Port > math.MaxUint16 {
+		return Service{}, nil, ErrMalformedAnnouncement
+	}
+
+	service := serviceFromAnnouncement(addr, announcement)
+	if err := validateService(&service); err != nil {
+		return Service{}, nil, err
+	}
+	return service, announcement, nil
+}
+
+func announcementTTL(announcement *model.ServiceAnnouncement, expiry time.Duration) (time.Duration, bool) {
+	// TTLs are only carried since version 2, older peers rely on the configured expiry.
+	if announcement.Version < 2 {
+		return expiry, true
+	}
+	if announcement.TtlMs == 0 {
+		return 0, false
+	}
+
+	ttl := time.Duration(announcement.TtlMs) * time.Millisecond
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl, true
+}
@@ -0,0 +1,138 @@
+package bonjour
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+)
+
+func FuzzParseAnnouncement(f *testing.F) {
+	for _, announcement := range []*model.ServiceAnnouncement{
+		{},
+		{Uuid: "*main.Request", Port: 1337},
+		{Uuid: "*main.Request", Port: 1337, Tags: []string{"a", "b"}},
+		{Uuid: "*main.Request", Port: 1337, Tags: make([]string, 16)},
+		{Uuid: "*main.Request", Port: -1},
+		{Uuid: "*main.Request", Port: 1337, Version: announcementVersion, Metadata: map[string]string{MetadataOS: "linux"}},
+		{Version: announcementVersion, Query: wildcardQuery, UnicastResponse: true},
+		{Uuid: "*main.Request", Port: 1337, Version: announcementVersion, Addresses: []string{"10.0.0.1", "fe80::1"}},
+	} {
+		data, err := proto.Marshal(announcement)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte{0xff, 0xff, 0xff})
+
+	addr := &net.UDPAddr{
+		IP:   net.IPv4(192, 168, 0, 1),
+		Port: ipv4Port,
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		service, announcement, err := parseAnnouncement(addr, data)
+		if err != nil {
+			return
+		}
+		if announcement.Query != "" {
+			if service.UUID != "" {
+				t.Fatalf("unexpected uuid %q for query", service.UUID)
+			}
+			return
+		}
+
+		if service.UUID == "" || service.UUID != announcement.Uuid {
+			t.Fatalf("unexpected uuid %q", service.UUID)
+		}
+		if len(announcement.Addresses) == 0 && service.Provider.Host != addr.IP.String() {
+			t.Fatalf("unexpected host %q", service.Provider.Host)
+		}
+		if err := validateService(&service); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestParseLegacyAnnouncement(t *testing.T) {
+	tags := make([]string, legacyTagsSize)
+	tags[0] = "gpu"
+	data, err := proto.Marshal(&model.ServiceAnnouncement{
+		Uuid: "*main.Request",
+		Port: 1337,
+		Tags: append(tags, "linux", "amd64", "node", "C"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, _, err := parseAnnouncement(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1)}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(service.Tags) != 1 || service.Tags[0] != "gpu" {
+		t.Fatalf("unexpected tags %v", service.Tags)
+	}
+	if service.Metadata[MetadataOS] != "linux" || service.Metadata[MetadataLang] != "C" {
+		t.Fatalf("unexpected metadata %v", service.Metadata)
+	}
+}
+
+func TestParseAnnouncedAddresses(t *testing.T) {
+	data, err := proto.Marshal(&model.ServiceAnnouncement{
+		Uuid:      "*main.Request",
+		Port:      1337,
+		Version:   announcementVersion,
+		Addresses: []string{"fe80::1", "bogus", "2001:db8::1", "ff02::1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, _, err := parseAnnouncement(&net.UDPAddr{IP: net.ParseIP("fe80::2"), Zone: "eth0"}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Provider{
+		{Host: "fe80::1", Zone: "eth0", Port: 1337},
+		{Host: "2001:db8::1", Port: 1337},
+	}
+	if got := service.Providers(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("providers = %+v, want %+v", got, want)
+	}
+
+	service, _, err = parseAnnouncement(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 2)}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := service.Providers(); !reflect.DeepEqual(got, want[1:]) {
+		t.Fatalf("providers = %+v, want %+v", got, want[1:])
+	}
+}
+
+func TestParseAnnouncedNetwork(t *testing.T) {
+	for network, want := range map[string]string{"udp": "udp", "unix": "", "": ""} {
+		data, err := proto.Marshal(&model.ServiceAnnouncement{
+			Uuid:      "*main.Request",
+			Port:      1337,
+			Version:   announcementVersion,
+			Addresses: []string{"10.0.0.1", "10.0.0.2"},
+			Metadata:  map[string]string{MetadataNetwork: network},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		service, _, err := parseAnnouncement(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1)}, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, provider := range service.Providers() {
+			if provider.Network != want {
+				t.Fatalf("%q: provider %+v, want network %q", network, provider, want)
+			}
+		}
+	}
+}
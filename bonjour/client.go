@@ -1,24 +1,48 @@
 package bonjour
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"reflect"
 	"sync"
 	"time"
-	"unsafe"
 
-	"github.com/golang/protobuf/proto"
 	model "github.com/t0rr3sp3dr0/middleair/proto"
 )
 
 var (
-	callbacks           = make(map[unsafe.Pointer]*struct{})
+	callbacks           = make(map[uint64]func(net.Addr, *model.ServiceAnnouncement))
+	callbackID          uint64
 	callbacksMutex      = &sync.RWMutex{}
-	remoteServices      = make(map[string]map[Service]time.Time)
+	remoteServices      = make(map[string]map[Provider]*remoteService)
 	remoteServicesMutex = &sync.RWMutex{}
+	callbackQueue       = make(chan callbackEvent, callbackQueueSize)
+
+	remoteServicesChanged = make(chan struct{})
+	limiter               = newRateLimiter(rateLimit, rateBurst)
+	limiterMutex          = &sync.Mutex{}
+
+	errCallbackQueueFull = errors.New("callback queue full, dropping announcement")
 )
 
-func ripperLoop() (ret error) {
+type datagram struct {
+	data []byte
+	addr *net.UDPAddr
+	err  error
+}
+
+type callbackEvent struct {
+	addr         *net.UDPAddr
+	announcement *model.ServiceAnnouncement
+}
+
+type remoteService struct {
+	service Service
+	expires time.Time
+}
+
+func ripperLoop(cfg *Config, stop <-chan struct{}) (ret error) {
 	defer func() {
 		if r := recover(); r != nil {
 			ret = fmt.Errorf("%v", r)
@@ -26,139 +50,232 @@ func ripperLoop() (ret error) {
 	}()
 
 	for {
-		var once sync.Once
 		remoteServicesMutex.Lock()
-		defer once.Do(remoteServicesMutex.Unlock)
 		for k, services := range remoteServices {
-			for service, timestamp := range services {
-				if time.Now().After(timestamp.Add(timeout)) {
-					delete(services, service)
+			for provider, remote := range services {
+				if time.Now().After(remote.expires) {
+					delete(services, provider)
+					publish(Event{Type: EventRemoved, Service: remote.service})
 				}
 			}
 			if len(services) == 0 {
 				delete(remoteServices, k)
 			}
 		}
-		once.Do(remoteServicesMutex.Unlock)
+		remoteServicesMutex.Unlock()
+
+		limiterMutex.Lock()
+		limiter.Prune(time.Now())
+		limiterMutex.Unlock()
 
-		time.Sleep(timeout)
+		select {
+		case <-time.After(cfg.Expiry / 4):
+		case <-stop:
+			return nil
+		}
 	}
 }
 
-func listenerLoop() (ret error) {
+func listenerLoop(cfg *Config, stop <-chan struct{}) (ret error) {
 	defer func() {
 		if r := recover(); r != nil {
 			ret = fmt.Errorf("%v", r)
 		}
 	}()
 
-	var connections []*net.UDPConn
-	for _, addr := range addresses {
-		conn, err := net.ListenMulticastUDP(addr.Network(), nil, addr)
+	var connections []*multicastConn
+	for _, group := range cfg.groups() {
+		conn, err := listenMulticast(group, cfg)
 		if err != nil {
-			return err
+			ret = err
+			logger.Println(group, err)
+			continue
 		}
 		defer conn.Close()
 
-		if err := conn.SetReadBuffer(datagramSize); err != nil {
+		if err := conn.SetReadBuffer(datagramSize + 1); err != nil {
 			return err
 		}
 
 		connections = append(connections, conn)
 	}
-
-	ch := make(chan func() ([]byte, *net.UDPAddr, error))
-	for _, conn := range connections {
-		go func(conn *net.UDPConn) {
-			for {
-				buffer := make([]byte, datagramSize)
-				n, addr, err := conn.ReadFromUDP(buffer)
-				ch <- func() ([]byte, *net.UDPAddr, error) {
-					return buffer[:n], addr, err
-				}
-			}
-		}(conn)
+	if len(connections) == 0 {
+		return ret
 	}
+	ret = nil
+
+	done := make(chan struct{})
+	defer close(done)
+	ch := receiveDatagrams(connections, done)
 
 	for {
-		for fn := range ch {
-			buffer, addr, err := fn()
-			if err != nil {
-				return err
-			}
+		select {
+		case <-stop:
+			return nil
 
-			announcement := &model.ServiceAnnouncement{}
-			if err := proto.Unmarshal(buffer, announcement); err != nil {
-				fmt.Println(err)
-				continue
+		case d := <-ch:
+			if d.err != nil {
+				return d.err
 			}
+			receive(cfg, d)
+		}
+	}
+}
 
-			remoteServicesMutex.RLock()
-			_, ok := remoteServices[announcement.Uuid]
-			remoteServicesMutex.RUnlock()
-			if !ok {
-				remoteServicesMutex.Lock()
-				remoteServices[announcement.Uuid] = make(map[Service]time.Time)
-				remoteServicesMutex.Unlock()
-			}
+func allow(addr *net.UDPAddr) bool {
+	limiterMutex.Lock()
+	defer limiterMutex.Unlock()
 
-			service := Service{
-				Provider: Provider{
-					Host: addr.IP.String(),
-					Port: uint16(announcement.Port),
-				},
-				Metadata: Metadata{
-					OS:   announcement.Tags[12],
-					Arch: announcement.Tags[13],
-					Host: announcement.Tags[14],
-					Lang: announcement.Tags[15],
-				},
-			}
-			copy(service.Tags[:], announcement.Tags)
-			remoteServicesMutex.Lock()
-			remoteServices[announcement.Uuid][service] = time.Now()
-			remoteServicesMutex.Unlock()
-
-			var once sync.Once
-			callbacksMutex.RLock()
-			defer once.Do(callbacksMutex.RUnlock)
-			for callback := range callbacks {
-				(*(*func(net.Addr, *model.ServiceAnnouncement))(callback))(net.Addr(addr), announcement)
-			}
-			once.Do(callbacksMutex.RUnlock)
+	return limiter.Allow(addr.IP.String(), time.Now())
+}
+
+func receive(cfg *Config, d datagram) {
+	if !allow(d.addr) {
+		return
+	}
+
+	service, announcement, err := parseAnnouncement(d.addr, d.data)
+	if err != nil {
+		if loggingLevel != LogDisabled {
+			logger.Println(d.addr, err)
 		}
+		return
+	}
+	if announcement.Namespace != cfg.Namespace {
+		return
 	}
+
+	if announcement.Query != "" {
+		respond(cfg, announcement.Query, announcement.UnicastResponse, d.addr)
+		return
+	}
+
+	ttl, alive := announcementTTL(announcement, cfg.Expiry)
+	storeRemoteService(service, ttl, alive)
+	notify(d.addr, announcement)
 }
 
-func RegisterCallback(fn func(net.Addr, *model.ServiceAnnouncement)) {
-	callbacksMutex.Lock()
-	defer callbacksMutex.Unlock()
+func storeRemoteService(service Service, ttl time.Duration, alive bool) {
+	remoteServicesMutex.Lock()
+	previous, known := remoteServices[service.UUID][service.Provider]
+	if alive {
+		if _, ok := remoteServices[service.UUID]; !ok {
+			remoteServices[service.UUID] = make(map[Provider]*remoteService)
+		}
+		remoteServices[service.UUID][service.Provider] = &remoteService{
+			service: service,
+			expires: time.Now().Add(ttl),
+		}
+
+		if !known {
+			publish(Event{Type: EventAdded, Service: service})
+		} else if !reflect.DeepEqual(previous.service, service) {
+			publish(Event{Type: EventUpdated, Service: service})
+		}
+	} else if known {
+		services := remoteServices[service.UUID]
+		delete(services, service.Provider)
+		if len(services) == 0 {
+			delete(remoteServices, service.UUID)
+		}
+		publish(Event{Type: EventRemoved, Service: previous.service})
+	}
+	close(remoteServicesChanged)
+	remoteServicesChanged = make(chan struct{})
+	remoteServicesMutex.Unlock()
+}
 
-	callbacks[unsafe.Pointer(&fn)] = nil
+func notify(addr *net.UDPAddr, announcement *model.ServiceAnnouncement) {
+	select {
+	case callbackQueue <- callbackEvent{addr: addr, announcement: announcement}:
+	default:
+		if loggingLevel != LogDisabled {
+			logger.Println(addr, errCallbackQueueFull)
+		}
+	}
 }
 
-func UnregisterCallback(fn func(net.Addr, *model.ServiceAnnouncement)) {
+func dispatcherLoop(cfg *Config, stop <-chan struct{}) (ret error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret = fmt.Errorf("%v", r)
+		}
+	}()
+
+	for {
+		var event callbackEvent
+		select {
+		case event = <-callbackQueue:
+		case <-stop:
+			return nil
+		}
+
+		callbacksMutex.RLock()
+		fns := make([]func(net.Addr, *model.ServiceAnnouncement), 0, len(callbacks))
+		for _, callback := range callbacks {
+			fns = append(fns, callback)
+		}
+		callbacksMutex.RUnlock()
+
+		for _, fn := range fns {
+			invokeCallback(fn, event)
+		}
+	}
+}
+
+func invokeCallback(fn func(net.Addr, *model.ServiceAnnouncement), event callbackEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Println(fmt.Errorf("callback panicked: %v", r))
+		}
+	}()
+
+	fn(net.Addr(event.addr), event.announcement)
+}
+
+// RegisterCallback delivers every announcement as it arrives, repeats
+// included, until the returned cancel function is called. Watch reports
+// changes to instances instead.
+func RegisterCallback(fn func(net.Addr, *model.ServiceAnnouncement)) (cancel func()) {
+	ensureStarted()
+	return registerCallback(fn)
+}
+
+func registerCallback(fn func(net.Addr, *model.ServiceAnnouncement)) func() {
 	callbacksMutex.Lock()
 	defer callbacksMutex.Unlock()
 
-	delete(callbacks, unsafe.Pointer(&fn))
+	callbackID++
+	id := callbackID
+	callbacks[id] = fn
+
+	return func() {
+		callbacksMutex.Lock()
+		defer callbacksMutex.Unlock()
+
+		delete(callbacks, id)
+	}
 }
 
 func RemoteServices() map[string][]Service {
+	ensureStarted()
+
 	remoteServicesMutex.RLock()
 	defer remoteServicesMutex.RUnlock()
 
 	m := make(map[string][]Service)
 	for uuid, services := range remoteServices {
 		m[uuid] = make([]Service, 0, len(services))
-		for service := range services {
-			m[uuid] = append(m[uuid], service)
+		for _, remote := range services {
+			m[uuid] = append(m[uuid], remote.service)
 		}
 	}
 	return m
 }
 
 func InstancesOfService(uuid string) []Service {
+	ensureStarted()
+
 	remoteServicesMutex.RLock()
 	defer remoteServicesMutex.RUnlock()
 
@@ -168,8 +285,8 @@ func InstancesOfService(uuid string) []Service {
 	}
 
 	instances := make([]Service, 0, len(services))
-	for service := range services {
-		instances = append(instances, service)
+	for _, remote := range services {
+		instances = append(instances, remote.service)
 	}
 	return instances
 }
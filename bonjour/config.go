@@ -0,0 +1,147 @@
+package bonjour
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+type Protocol int
+
+const (
+	ProtocolNative Protocol = 0x01
+	ProtocolMDNS   Protocol = 0x02
+	ProtocolAll    Protocol = ProtocolNative | ProtocolMDNS
+)
+
+type Config struct {
+	Protocols           Protocol
+	BrowseTypes         []string
+	Interfaces          []net.Interface
+	IPv4Group           *net.UDPAddr
+	IPv6Group           *net.UDPAddr
+	DisableIPv4         bool
+	DisableIPv6         bool
+	TTL                 int
+	AnnounceInterval    time.Duration
+	MaxAnnounceInterval time.Duration
+	Expiry              time.Duration
+	Namespace           string
+
+	// Addresses are advertised for services registered without a host. When
+	// empty, the addresses of Interfaces are enumerated on start.
+	Addresses []net.IP
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Protocols: ProtocolNative,
+		IPv4Group: &net.UDPAddr{
+			IP:   net.ParseIP(ipv4Host),
+			Port: ipv4Port,
+		},
+		IPv6Group: &net.UDPAddr{
+			IP:   net.ParseIP(ipv6Host),
+			Port: ipv6Port,
+		},
+		TTL:                 defaultTTL,
+		AnnounceInterval:    defaultAnnounceInterval,
+		MaxAnnounceInterval: defaultMaxInterval,
+		Expiry:              defaultExpiry,
+	}
+}
+
+func (e *Config) setDefaults() error {
+	defaults := DefaultConfig()
+
+	if e.Protocols == 0 {
+		e.Protocols = defaults.Protocols
+	}
+	if e.IPv4Group == nil {
+		e.IPv4Group = defaults.IPv4Group
+	}
+	if e.IPv6Group == nil {
+		e.IPv6Group = defaults.IPv6Group
+	}
+	if e.TTL == 0 {
+		e.TTL = defaults.TTL
+	}
+	if e.AnnounceInterval == 0 {
+		e.AnnounceInterval = defaults.AnnounceInterval
+	}
+	if e.MaxAnnounceInterval == 0 {
+		e.MaxAnnounceInterval = defaults.MaxAnnounceInterval
+	}
+	if e.MaxAnnounceInterval < e.AnnounceInterval {
+		e.MaxAnnounceInterval = e.AnnounceInterval
+	}
+	if e.Expiry == 0 {
+		e.Expiry = defaults.Expiry
+	}
+
+	if e.Protocols&^ProtocolAll != 0 {
+		return fmt.Errorf("Invalid Protocols: %d", e.Protocols)
+	}
+	for _, serviceType := range e.BrowseTypes {
+		if _, err := mdnsTypeName(serviceType); err != nil {
+			return err
+		}
+	}
+	if e.IPv4Group.IP.To4() == nil || !e.IPv4Group.IP.IsMulticast() {
+		return fmt.Errorf("Invalid IPv4 Group: %v", e.IPv4Group)
+	}
+	if e.IPv6Group.IP.To4() != nil || !e.IPv6Group.IP.IsMulticast() || e.IPv6Group.IP.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("Invalid IPv6 Group: %v", e.IPv6Group)
+	}
+	if len(e.Addresses) > maxAddresses {
+		return fmt.Errorf("Too Many Addresses: %d", len(e.Addresses))
+	}
+	for _, ip := range e.Addresses {
+		if ip == nil || ip.IsUnspecified() || ip.IsMulticast() {
+			return fmt.Errorf("Invalid Address: %v", ip)
+		}
+	}
+	if e.DisableIPv4 && e.DisableIPv6 {
+		return fmt.Errorf("No Multicast Group Enabled")
+	}
+	if e.TTL < 0 || e.TTL > 255 {
+		return fmt.Errorf("Invalid TTL: %d", e.TTL)
+	}
+	if e.AnnounceInterval <= 0 || e.Expiry < e.AnnounceInterval {
+		return fmt.Errorf("Invalid Intervals: announce %v, expiry %v", e.AnnounceInterval, e.Expiry)
+	}
+	if len(e.Namespace) > maxFieldSize {
+		return util.ErrPayloadTooLarge
+	}
+	return nil
+}
+
+func (e *Config) groups() []*net.UDPAddr {
+	var groups []*net.UDPAddr
+	if !e.DisableIPv4 {
+		groups = append(groups, e.IPv4Group)
+	}
+	if !e.DisableIPv6 {
+		groups = append(groups, e.IPv6Group)
+	}
+	return groups
+}
+
+func (e *Config) mdnsGroups() []*net.UDPAddr {
+	var groups []*net.UDPAddr
+	if !e.DisableIPv4 {
+		groups = append(groups, &net.UDPAddr{
+			IP:   net.ParseIP(mdnsIPv4Host),
+			Port: mdnsPort,
+		})
+	}
+	if !e.DisableIPv6 {
+		groups = append(groups, &net.UDPAddr{
+			IP:   net.ParseIP(mdnsIPv6Host),
+			Port: mdnsPort,
+		})
+	}
+	return groups
+}
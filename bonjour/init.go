@@ -3,75 +3,172 @@ package bonjour
 import (
 	"fmt"
 	"net"
-	"strings"
+	"sync"
 	"time"
 
 	model "github.com/t0rr3sp3dr0/middleair/proto"
 )
 
 const (
-	ipv4Host     = "224.0.0.57"
-	ipv4Port     = 13374
-	ipv6Host     = "ff01::39"
-	ipv6Port     = 13376
-	datagramSize = 8192
-	timeout      = 2 * time.Second
+	ipv4Host                = "224.0.0.57"
+	ipv4Port                = 13374
+	ipv6Host                = "ff02::39"
+	ipv6Port                = 13376
+	mdnsIPv4Host            = "224.0.0.251"
+	mdnsIPv6Host            = "ff02::fb"
+	mdnsPort                = 5353
+	mdnsTTL                 = 255
+	datagramSize            = 8192
+	callbackQueueSize       = 256
+	outboxSize              = 256
+	defaultTTL              = 1
+	defaultAnnounceInterval = 500 * time.Millisecond
+	defaultMaxInterval      = 8 * time.Second
+	defaultQueryInterval    = 250 * time.Millisecond
+	defaultExpiry           = 2 * time.Second
+	maxTTL                  = time.Hour
+	restartDelay            = time.Second
 )
 
 var (
-	addresses = []*net.UDPAddr{
-		// IPv4
-		func() *net.UDPAddr {
-			addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", ipv4Host, ipv4Port))
-			if err != nil {
-				panic(err)
-			}
-			return addr
-		}(),
-
-		// IPv6
-		func() *net.UDPAddr {
-			addr, err := net.ResolveUDPAddr("udp6", fmt.Sprintf("[%s]:%d", ipv6Host, ipv6Port))
-			if err != nil {
-				panic(err)
-			}
-			return addr
-		}(),
-	}[:1]
+	ErrAlreadyStarted = fmt.Errorf("Already Started")
+	ErrNotStarted     = fmt.Errorf("Not Started")
+
+	running      *discovery
+	autoStart    = true
+	runningMutex = &sync.Mutex{}
 )
 
-func init() {
-	go func() {
-		for {
-			logger.Println(ripperLoop())
-		}
-	}()
-	go func() {
-		for {
-			logger.Println(listenerLoop())
-		}
-	}()
-	go func() {
-		for {
-			logger.Println(broadcasterLoop())
+type discovery struct {
+	config    Config
+	stop      chan struct{}
+	waitGroup sync.WaitGroup
+	cancel    func()
+}
+
+func Start(cfg Config) error {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+
+	if running != nil {
+		return ErrAlreadyStarted
+	}
+	if err := cfg.setDefaults(); err != nil {
+		return err
+	}
+
+	autoStart = false
+	running = start(cfg)
+	return nil
+}
+
+// Stop halts discovery and disables the automatic start on first use.
+func Stop() {
+	runningMutex.Lock()
+	e := running
+	running = nil
+	autoStart = false
+	runningMutex.Unlock()
+
+	if e == nil {
+		return
+	}
+
+	close(e.stop)
+	e.waitGroup.Wait()
+	e.cancel()
+
+	remoteServicesMutex.Lock()
+	for _, services := range remoteServices {
+		for _, remote := range services {
+			publish(Event{Type: EventRemoved, Service: remote.service})
 		}
-	}()
+	}
+	remoteServices = make(map[string]map[Provider]*remoteService)
+	remoteServicesMutex.Unlock()
+}
+
+func ensureStarted() {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
 
-	s := &Service{
-		UUID: "HELO",
+	if running != nil || !autoStart {
+		return
 	}
+
+	cfg := DefaultConfig()
+	if err := cfg.setDefaults(); err != nil {
+		panic(err)
+	}
+	autoStart = false
+	running = start(cfg)
+}
+
+func currentConfig() (Config, bool) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+
+	if running == nil {
+		return Config{}, false
+	}
+	return running.config, true
+}
+
+func start(cfg Config) *discovery {
+	if len(cfg.Addresses) == 0 {
+		cfg.Addresses = localAddresses(&cfg)
+	}
+
+	e := &discovery{
+		config: cfg,
+		stop:   make(chan struct{}),
+	}
+
+	e.run(ripperLoop)
+	if cfg.Protocols&ProtocolNative != 0 {
+		e.run(listenerLoop)
+		e.run(broadcasterLoop)
+	}
+	if cfg.Protocols&ProtocolMDNS != 0 {
+		e.run(mdnsLoop)
+	}
+	e.run(dispatcherLoop)
+
 	c := func(addr net.Addr, announcement *model.ServiceAnnouncement) {
-		host := strings.Split(addr.String(), ":")[0]
-		b := host == s.Provider.Host
+		if loggingLevel == LogDisabled {
+			return
+		}
+
+		udpAddr, ok := addr.(*net.UDPAddr)
+		b := ok && isLocalAddress(udpAddr.IP)
 		if (b && loggingLevel&LogLocalhost != LogDisabled) || (!b && loggingLevel&LogOthers != LogDisabled) {
 			logger.Println(addr, announcement)
 		}
+	}
+	e.cancel = registerCallback(c)
+
+	return e
+}
+
+func (e *discovery) run(loop func(*Config, <-chan struct{}) error) {
+	e.waitGroup.Add(1)
+	go func() {
+		defer e.waitGroup.Done()
+
+		for {
+			err := loop(&e.config, e.stop)
+			select {
+			case <-e.stop:
+				return
+			default:
+			}
 
-		if announcement.Uuid == s.UUID {
-			s.Provider.Host = host
-			UnregisterService(s)
+			logger.Println(err)
+			select {
+			case <-time.After(restartDelay):
+			case <-e.stop:
+				return
+			}
 		}
-	}
-	RegisterCallback(c)
-	RegisterService(s)
+	}()
 }
@@ -0,0 +1,768 @@
+package bonjour
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+const (
+	mdnsDomain           = "local."
+	mdnsServiceName      = "_middleair._tcp.local."
+	mdnsEnumerationName  = "_services._dns-sd._udp.local."
+	mdnsDefaultHost      = "middleair"
+	mdnsUUIDKey          = "uuid"
+	mdnsNamespaceKey     = "ns"
+	mdnsCacheFlush       = 0x8000
+	mdnsUnicastResponse  = 0x8000
+	mdnsMaxLabelSize     = 63
+	mdnsMaxTypeSize      = 16
+	mdnsMaxTextSize      = 255
+	mdnsLegacyTTL        = 10 * time.Second
+	mdnsMaxUnicastSize   = 512
+	mdnsMaxQueryInterval = time.Minute
+)
+
+var (
+	mdnsQueries = make(chan struct{}, 1)
+)
+
+type mdnsState struct {
+	announceState
+	service Service
+}
+
+type mdnsResponder struct {
+	cfg       *Config
+	host      string
+	addrs     []net.IP
+	subnets   []*net.IPNet
+	types     []string
+	states    map[*Service]*mdnsState
+	instances map[string]*remoteService
+}
+
+func mdnsLoop(cfg *Config, stop <-chan struct{}) (ret error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret = fmt.Errorf("%v", r)
+		}
+	}()
+
+	// RFC 6762 asks for an IP TTL of 255 regardless of the native scope.
+	mcfg := *cfg
+	mcfg.TTL = mdnsTTL
+
+	var connections []*multicastConn
+	for _, group := range cfg.mdnsGroups() {
+		conn, err := listenMulticast(group, &mcfg)
+		if err != nil {
+			ret = err
+			logger.Println(group, err)
+			continue
+		}
+		defer conn.Close()
+
+		if err := conn.SetReadBuffer(datagramSize + 1); err != nil {
+			return err
+		}
+
+		connections = append(connections, conn)
+	}
+	if len(connections) == 0 {
+		return ret
+	}
+	ret = nil
+
+	done := make(chan struct{})
+	defer close(done)
+	ch := receiveDatagrams(connections, done)
+
+	send := func(p packet) error {
+		return sendPacket(connections, p)
+	}
+
+	e := newMDNSResponder(cfg)
+	query, err := e.browse(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := send(packet{data: query}); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	queryInterval := cfg.AnnounceInterval
+	queryTimer := time.NewTimer(queryInterval)
+	defer queryTimer.Stop()
+
+	for {
+		changed := servicesChanged()
+		select {
+		case <-timer.C:
+			for _, message := range e.announcements(time.Now()) {
+				if err := send(packet{data: message}); err != nil {
+					return err
+				}
+			}
+			timer.Reset(e.next(time.Now()))
+
+		case <-changed:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(0)
+
+		case <-mdnsQueries:
+			if !queryTimer.Stop() {
+				select {
+				case <-queryTimer.C:
+				default:
+				}
+			}
+			queryInterval = cfg.AnnounceInterval
+			queryTimer.Reset(0)
+
+		case <-queryTimer.C:
+			query, err := e.browse(time.Now())
+			if err != nil {
+				return err
+			}
+			if err := send(packet{data: query}); err != nil {
+				return err
+			}
+
+			queryInterval *= 2
+			if queryInterval > mdnsMaxQueryInterval {
+				queryInterval = mdnsMaxQueryInterval
+			}
+			queryTimer.Reset(queryInterval)
+
+		case d := <-ch:
+			if d.err != nil {
+				return d.err
+			}
+			for _, p := range e.receive(d) {
+				if err := send(p); err != nil {
+					return err
+				}
+			}
+
+		case <-stop:
+			for _, state := range e.states {
+				message, err := e.announcement(&state.service, 0)
+				if err != nil {
+					continue
+				}
+				if err := send(packet{data: message}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+}
+
+func newMDNSResponder(cfg *Config) *mdnsResponder {
+	e := &mdnsResponder{
+		cfg:       cfg,
+		host:      mdnsHostname(),
+		addrs:     mdnsAddresses(cfg),
+		subnets:   localSubnets(cfg),
+		types:     []string{mdnsServiceName},
+		states:    make(map[*Service]*mdnsState),
+		instances: make(map[string]*remoteService),
+	}
+
+	for _, serviceType := range cfg.BrowseTypes {
+		name, err := mdnsTypeName(serviceType)
+		if err != nil || name == mdnsServiceName {
+			continue
+		}
+		e.types = append(e.types, name)
+	}
+	return e
+}
+
+func mdnsTypeName(serviceType string) (string, error) {
+	name := dns.Fqdn(strings.ToLower(serviceType))
+	if !strings.HasSuffix(name, "."+mdnsDomain) {
+		name += mdnsDomain
+	}
+
+	labels := dns.SplitDomainName(name)
+	if len(labels) != 3 || len(labels[0]) < 2 || len(labels[0]) > mdnsMaxTypeSize || labels[0][0] != '_' {
+		return "", fmt.Errorf("Invalid Browse Type: %v", serviceType)
+	}
+	if labels[1] != "_tcp" && labels[1] != "_udp" {
+		return "", fmt.Errorf("Invalid Browse Type: %v", serviceType)
+	}
+	return name, nil
+}
+
+func mdnsLabel(s string, size int) string {
+	label := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, s)
+
+	label = strings.Trim(label, "-")
+	if len(label) > size {
+		label = label[:size]
+	}
+	return label
+}
+
+func mdnsHostname() string {
+	hostname, _ := os.Hostname()
+	if i := strings.IndexByte(hostname, '.'); i >= 0 {
+		hostname = hostname[:i]
+	}
+
+	label := mdnsLabel(hostname, mdnsMaxLabelSize)
+	if label == "" {
+		label = mdnsDefaultHost
+	}
+	return label + "." + mdnsDomain
+}
+
+func mdnsAddresses(cfg *Config) []net.IP {
+	var addrs []net.IP
+	for _, ip := range cfg.Addresses {
+		ipv4 := ip.To4() != nil
+		if (ipv4 && !cfg.DisableIPv4) || (!ipv4 && !cfg.DisableIPv6) {
+			addrs = append(addrs, ip)
+		}
+	}
+	return addrs
+}
+
+func mdnsSeconds(ttl time.Duration) uint32 {
+	return uint32((ttl + time.Second - 1) / time.Second)
+}
+
+func mdnsMatches(q dns.Question, rrtype uint16) bool {
+	return q.Qtype == rrtype || q.Qtype == dns.TypeANY
+}
+
+// Instance labels are capped at 63 bytes, so long UUIDs are shortened and a
+// hash of the full identity keeps them unique on the link.
+func (e *mdnsResponder) instanceName(service *Service) string {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s\x00%s\x00%d", service.UUID, e.host, service.Provider.Port)
+
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+	return mdnsLabel(service.UUID, mdnsMaxLabelSize-len(suffix)) + suffix + "." + mdnsServiceName
+}
+
+func (e *mdnsResponder) text(service *Service) []string {
+	text := []string{mdnsUUIDKey + "=" + service.UUID}
+	if e.cfg.Namespace != "" {
+		text = append(text, mdnsNamespaceKey+"="+e.cfg.Namespace)
+	}
+
+	for _, tag := range service.Tags {
+		if tag != "" && len(tag) <= mdnsMaxTextSize {
+			text = append(text, tag)
+		}
+	}
+
+	keys := make([]string, 0, len(service.Metadata))
+	for k := range service.Metadata {
+		if k == "" || strings.EqualFold(k, mdnsUUIDKey) || strings.EqualFold(k, mdnsNamespaceKey) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if entry := k + "=" + service.Metadata[k]; len(entry) <= mdnsMaxTextSize {
+			text = append(text, entry)
+		}
+	}
+	return text
+}
+
+func (e *mdnsResponder) serviceRecords(service *Service, ttl time.Duration) (*dns.PTR, *dns.SRV, *dns.TXT) {
+	instance := e.instanceName(service)
+	seconds := mdnsSeconds(ttl)
+
+	ptr := &dns.PTR{
+		Hdr: dns.RR_Header{Name: mdnsServiceName, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: seconds},
+		Ptr: instance,
+	}
+	srv := &dns.SRV{
+		Hdr:    dns.RR_Header{Name: instance, Rrtype: dns.TypeSRV, Class: dns.ClassINET | mdnsCacheFlush, Ttl: seconds},
+		Port:   service.Provider.Port,
+		Target: e.host,
+	}
+	txt := &dns.TXT{
+		Hdr: dns.RR_Header{Name: instance, Rrtype: dns.TypeTXT, Class: dns.ClassINET | mdnsCacheFlush, Ttl: seconds},
+		Txt: e.text(service),
+	}
+	return ptr, srv, txt
+}
+
+func (e *mdnsResponder) enumerationRecord(ttl time.Duration) dns.RR {
+	return &dns.PTR{
+		Hdr: dns.RR_Header{Name: mdnsEnumerationName, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: mdnsSeconds(ttl)},
+		Ptr: mdnsServiceName,
+	}
+}
+
+func (e *mdnsResponder) addressRecords(rrtype uint16, ttl time.Duration) []dns.RR {
+	var records []dns.RR
+	for _, ip := range e.addrs {
+		if ipv4 := ip.To4(); ipv4 != nil && rrtype != dns.TypeAAAA {
+			records = append(records, &dns.A{
+				Hdr: dns.RR_Header{Name: e.host, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: mdnsSeconds(ttl)},
+				A:   ipv4,
+			})
+		} else if ipv4 == nil && rrtype != dns.TypeA {
+			records = append(records, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: e.host, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: mdnsSeconds(ttl)},
+				AAAA: ip,
+			})
+		}
+	}
+	return records
+}
+
+func packMDNS(msg *dns.Msg) ([]byte, error) {
+	msg.Compress = true
+	data, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > datagramSize {
+		return nil, util.ErrPayloadTooLarge
+	}
+	return data, nil
+}
+
+// truncateMDNS drops records off the end of msg until it packs into size
+// bytes, flagging it as truncated once answers go too.
+func truncateMDNS(msg *dns.Msg, size int) {
+	msg.Compress = true
+	for msg.Len() > size {
+		if n := len(msg.Extra); n > 0 {
+			msg.Extra = msg.Extra[:n-1]
+		} else if n := len(msg.Answer); n > 0 {
+			msg.Answer = msg.Answer[:n-1]
+			msg.Truncated = true
+		} else {
+			return
+		}
+	}
+}
+
+func (e *mdnsResponder) announcement(service *Service, ttl time.Duration) ([]byte, error) {
+	ptr, srv, txt := e.serviceRecords(service, ttl)
+
+	msg := &dns.Msg{}
+	msg.Response = true
+	msg.Authoritative = true
+	msg.Answer = []dns.RR{ptr, srv, txt}
+	if ttl > 0 {
+		msg.Answer = append(msg.Answer, e.enumerationRecord(ttl))
+		msg.Extra = e.addressRecords(dns.TypeANY, ttl)
+	}
+	return packMDNS(msg)
+}
+
+func (e *mdnsResponder) sync() []Service {
+	registeredServicesMutex.RLock()
+	defer registeredServicesMutex.RUnlock()
+
+	for service := range registeredServices {
+		state, ok := e.states[service]
+		if !ok {
+			state = &mdnsState{}
+			e.states[service] = state
+		}
+		// Changed metadata is announced again from the start.
+		if ok && !reflect.DeepEqual(state.service.Metadata, service.Metadata) {
+			state.announceState = announceState{}
+		}
+		state.service = *service
+	}
+
+	var goodbyes []Service
+	for service, state := range e.states {
+		if _, ok := registeredServices[service]; !ok {
+			goodbyes = append(goodbyes, state.service)
+			delete(e.states, service)
+		}
+	}
+	return goodbyes
+}
+
+func (e *mdnsResponder) announcements(now time.Time) [][]byte {
+	var messages [][]byte
+	for _, service := range e.sync() {
+		message, err := e.announcement(&service, 0)
+		if err != nil {
+			logger.Println(service.UUID, err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	for _, state := range e.states {
+		ttl, ok := state.due(e.cfg, now)
+		if !ok {
+			continue
+		}
+
+		message, err := e.announcement(&state.service, ttl)
+		if err != nil {
+			logger.Println(state.service.UUID, err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func (e *mdnsResponder) next(now time.Time) time.Duration {
+	next := e.cfg.MaxAnnounceInterval
+	for _, state := range e.states {
+		if d := state.next.Sub(now); d < next {
+			next = d
+		}
+	}
+	return next
+}
+
+func (e *mdnsResponder) browse(now time.Time) ([]byte, error) {
+	for name, remote := range e.instances {
+		if now.After(remote.expires) {
+			delete(e.instances, name)
+		}
+	}
+
+	query := &dns.Msg{}
+	for _, name := range e.types {
+		query.Question = append(query.Question, dns.Question{Name: name, Qtype: dns.TypePTR, Qclass: dns.ClassINET})
+	}
+	return packMDNS(query)
+}
+
+func (e *mdnsResponder) receive(d datagram) []packet {
+	if !allow(d.addr) {
+		return nil
+	}
+
+	msg := &dns.Msg{}
+	if err := msg.Unpack(d.data); err != nil {
+		if loggingLevel != LogDisabled {
+			logger.Println(d.addr, err)
+		}
+		return nil
+	}
+	if msg.Opcode != dns.OpcodeQuery || msg.Rcode != dns.RcodeSuccess {
+		return nil
+	}
+
+	if !msg.Response {
+		return e.answer(msg, d.addr)
+	}
+	return e.learn(msg, d.addr)
+}
+
+func (e *mdnsResponder) answer(query *dns.Msg, addr *net.UDPAddr) []packet {
+	// Queries from ports other than 5353 come from one-shot resolvers that
+	// expect a conventional unicast DNS reply.
+	legacy := addr.Port != mdnsPort
+	unicast := legacy
+	ttl := announcementTTLFor(e.cfg, e.cfg.MaxAnnounceInterval)
+
+	var answers, extras []dns.RR
+	addresses := false
+	for _, q := range query.Question {
+		if q.Qclass&mdnsUnicastResponse != 0 {
+			unicast = true
+		}
+
+		name := strings.ToLower(q.Name)
+		if name == mdnsEnumerationName && mdnsMatches(q, dns.TypePTR) && len(e.states) > 0 {
+			answers = append(answers, e.enumerationRecord(ttl))
+		}
+		if name == strings.ToLower(e.host) {
+			answers = append(answers, e.addressRecords(q.Qtype, ttl)...)
+		}
+
+		for _, state := range e.states {
+			ptr, srv, txt := e.serviceRecords(&state.service, announcementTTLFor(e.cfg, state.interval))
+			if name == mdnsServiceName && mdnsMatches(q, dns.TypePTR) {
+				answers = append(answers, ptr)
+				extras = append(extras, srv, txt)
+				addresses = true
+			}
+			if name == strings.ToLower(srv.Hdr.Name) {
+				if mdnsMatches(q, dns.TypeSRV) {
+					answers = append(answers, srv)
+					addresses = true
+				}
+				if mdnsMatches(q, dns.TypeTXT) {
+					answers = append(answers, txt)
+				}
+			}
+		}
+	}
+	if len(answers) == 0 {
+		return nil
+	}
+	// Unicast replies go wherever the source of the query says, so only
+	// those on the link get any, and no larger than conventional DNS ones,
+	// lest the responder amplify spoofed queries.
+	if unicast && !onSubnet(e.subnets, addr.IP) {
+		return nil
+	}
+	if addresses {
+		extras = append(extras, e.addressRecords(dns.TypeANY, ttl)...)
+	}
+
+	msg := &dns.Msg{}
+	msg.Response = true
+	msg.Authoritative = true
+	msg.Answer = answers
+	msg.Extra = extras
+	if legacy {
+		msg.Id = query.Id
+		msg.Question = query.Question
+		for _, records := range [][]dns.RR{msg.Answer, msg.Extra} {
+			for _, rr := range records {
+				hdr := rr.Header()
+				hdr.Class &^= mdnsCacheFlush
+				if hdr.Ttl > mdnsSeconds(mdnsLegacyTTL) {
+					hdr.Ttl = mdnsSeconds(mdnsLegacyTTL)
+				}
+			}
+		}
+	}
+
+	if unicast {
+		truncateMDNS(msg, mdnsMaxUnicastSize)
+	}
+
+	data, err := packMDNS(msg)
+	if err != nil {
+		logger.Println(addr, err)
+		return nil
+	}
+
+	p := packet{data: data}
+	if unicast {
+		p.to = addr
+	}
+	return []packet{p}
+}
+
+func (e *mdnsResponder) learn(msg *dns.Msg, addr *net.UDPAddr) []packet {
+	srvs := make(map[string]*dns.SRV)
+	texts := make(map[string][]string)
+	addrs := make(map[string][]net.IP)
+	var ptrs []*dns.PTR
+	for _, records := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range records {
+			name := strings.ToLower(rr.Header().Name)
+			switch rr := rr.(type) {
+			case *dns.PTR:
+				ptrs = append(ptrs, rr)
+			case *dns.SRV:
+				srvs[name] = rr
+			case *dns.TXT:
+				texts[name] = rr.Txt
+			case *dns.A:
+				addrs[name] = append(addrs[name], rr.A)
+			case *dns.AAAA:
+				addrs[name] = append(addrs[name], rr.AAAA)
+			}
+		}
+	}
+
+	now := time.Now()
+	for name, srv := range srvs {
+		typeName, ok := e.browsing(name)
+		if !ok {
+			continue
+		}
+
+		service, namespace, err := e.serviceFromRecords(typeName, srv, texts[name], addrs[strings.ToLower(srv.Target)], addr)
+		if err != nil {
+			if loggingLevel != LogDisabled {
+				logger.Println(addr, err)
+			}
+			continue
+		}
+		if namespace != e.cfg.Namespace {
+			continue
+		}
+
+		ttl := time.Duration(srv.Hdr.Ttl) * time.Second
+		if ttl > maxTTL {
+			ttl = maxTTL
+		}
+		if ttl > 0 {
+			e.instances[name] = &remoteService{service: service, expires: now.Add(ttl)}
+		} else {
+			delete(e.instances, name)
+		}
+
+		storeRemoteService(service, ttl, ttl > 0)
+		notify(addr, newAnnouncement(&service, namespace, ttl))
+	}
+
+	// Responders may answer a browse with bare PTR records, in which case the
+	// instances still have to be resolved.
+	var questions []dns.Question
+	for _, ptr := range ptrs {
+		instance := strings.ToLower(ptr.Ptr)
+		if _, ok := srvs[instance]; ok {
+			continue
+		}
+		if typeName, ok := e.browsing(instance); !ok || typeName != strings.ToLower(ptr.Hdr.Name) {
+			continue
+		}
+
+		remote, known := e.instances[instance]
+		if ptr.Hdr.Ttl == 0 {
+			if known {
+				delete(e.instances, instance)
+				storeRemoteService(remote.service, 0, false)
+				notify(addr, newAnnouncement(&remote.service, e.cfg.Namespace, 0))
+			}
+			continue
+		}
+		if !known || now.After(remote.expires) {
+			questions = append(questions,
+				dns.Question{Name: ptr.Ptr, Qtype: dns.TypeSRV, Qclass: dns.ClassINET},
+				dns.Question{Name: ptr.Ptr, Qtype: dns.TypeTXT, Qclass: dns.ClassINET},
+			)
+		}
+	}
+	if len(questions) == 0 {
+		return nil
+	}
+
+	query := &dns.Msg{}
+	query.Question = questions
+	data, err := packMDNS(query)
+	if err != nil {
+		return nil
+	}
+	return []packet{{data: data}}
+}
+
+func (e *mdnsResponder) browsing(instance string) (string, bool) {
+	for _, name := range e.types {
+		if strings.HasSuffix(instance, "."+name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (e *mdnsResponder) serviceFromRecords(typeName string, srv *dns.SRV, text []string, addrs []net.IP, addr *net.UDPAddr) (Service, string, error) {
+	service := Service{
+		UUID: strings.TrimSuffix(typeName, "."+mdnsDomain),
+		Provider: Provider{
+			Host: addr.IP.String(),
+			Zone: addr.Zone,
+			Port: srv.Port,
+		},
+		Metadata: make(map[string]string),
+	}
+	if ip := mdnsProviderAddress(addrs, addr.IP); ip != nil {
+		service.Provider.Host = ip.String()
+		if !ip.IsLinkLocalUnicast() {
+			service.Provider.Zone = ""
+		}
+	}
+
+	hosts := make([]string, 0, len(addrs))
+	for _, ip := range addrs {
+		hosts = append(hosts, ip.String())
+	}
+	for _, provider := range announcedProviders(hosts, addr, srv.Port) {
+		if provider != service.Provider && len(service.Addresses) < maxAddresses {
+			service.Addresses = append(service.Addresses, provider)
+		}
+	}
+
+	// Foreign services know nothing about namespaces and are visible in all of them.
+	own := typeName == mdnsServiceName
+	uuid, namespace := "", e.cfg.Namespace
+	if own {
+		namespace = ""
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range text {
+		key, value, hasValue := entry, "", false
+		if i := strings.IndexByte(entry, '='); i >= 0 {
+			key, value, hasValue = entry[:i], entry[i+1:], true
+		}
+
+		// Only the first occurrence of a key counts, as per RFC 6763.
+		if key == "" || seen[strings.ToLower(key)] {
+			continue
+		}
+		seen[strings.ToLower(key)] = true
+
+		switch {
+		case own && strings.EqualFold(key, mdnsUUIDKey):
+			uuid = value
+		case own && strings.EqualFold(key, mdnsNamespaceKey):
+			namespace = value
+		case hasValue:
+			service.Metadata[key] = value
+		default:
+			service.Tags = append(service.Tags, key)
+		}
+	}
+	if own {
+		if uuid == "" {
+			return Service{}, "", ErrMalformedAnnouncement
+		}
+		service.UUID = uuid
+	}
+	service.decodeNetwork()
+
+	if err := validateService(&service); err != nil {
+		return Service{}, "", err
+	}
+	return service, namespace, nil
+}
+
+// Proxies may announce services on behalf of other hosts, in which case the
+// address records of the target win over the source of the packet.
+func mdnsProviderAddress(addrs []net.IP, source net.IP) net.IP {
+	for _, ip := range addrs {
+		if ip.Equal(source) {
+			return nil
+		}
+	}
+	for _, ip := range addrs {
+		if (ip.To4() != nil) == (source.To4() != nil) {
+			return ip
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package bonjour
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestMDNSAnnouncementRoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Namespace = "staging"
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	e := newMDNSResponder(&cfg)
+
+	service := Service{
+		UUID:     "*demo.Request",
+		Provider: Provider{Port: 8080},
+		Tags:     []string{"fast"},
+		Metadata: map[string]string{"zone": "a", "uuid": "ignored"},
+	}
+	data, err := e.announcement(&service, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &dns.Msg{}
+	if err := msg.Unpack(data); err != nil {
+		t.Fatal(err)
+	}
+	srv, ok := msg.Answer[1].(*dns.SRV)
+	if !ok {
+		t.Fatalf("unexpected record %v", msg.Answer[1])
+	}
+	txt, ok := msg.Answer[2].(*dns.TXT)
+	if !ok {
+		t.Fatalf("unexpected record %v", msg.Answer[2])
+	}
+
+	addr := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: mdnsPort}
+	got, namespace, err := e.serviceFromRecords(mdnsServiceName, srv, txt.Txt, nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if namespace != cfg.Namespace {
+		t.Errorf("namespace = %q, want %q", namespace, cfg.Namespace)
+	}
+
+	want := Service{
+		UUID:     service.UUID,
+		Provider: Provider{Host: "192.0.2.1", Port: 8080},
+		Tags:     []string{"fast"},
+		Metadata: map[string]string{"zone": "a"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("service = %+v, want %+v", got, want)
+	}
+}
+
+// mdnsResponderFor answers for service, at 192.0.2.10 on 192.0.2.0/24,
+// browsing types too.
+func mdnsResponderFor(t *testing.T, service *Service, types ...string) *mdnsResponder {
+	runningMutex.Lock()
+	autoStart = false
+	runningMutex.Unlock()
+
+	cfg := DefaultConfig()
+	cfg.BrowseTypes = types
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	e := newMDNSResponder(&cfg)
+	e.addrs = []net.IP{net.ParseIP("192.0.2.10")}
+	_, subnet, _ := net.ParseCIDR("192.0.2.0/24")
+	e.subnets = []*net.IPNet{subnet}
+	if service != nil {
+		e.states[service] = &mdnsState{service: *service}
+	}
+	return e
+}
+
+// mdnsRecords are those a peer at 192.0.2.20 announces instance with.
+func mdnsRecords(typeName string, instance string, port uint16, text []string, ttl uint32) []dns.RR {
+	return []dns.RR{
+		&dns.PTR{Hdr: dns.RR_Header{Name: typeName, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: ttl}, Ptr: instance},
+		&dns.SRV{Hdr: dns.RR_Header{Name: instance, Rrtype: dns.TypeSRV, Class: dns.ClassINET | mdnsCacheFlush, Ttl: ttl}, Port: port, Target: "peer.local."},
+		&dns.TXT{Hdr: dns.RR_Header{Name: instance, Rrtype: dns.TypeTXT, Class: dns.ClassINET | mdnsCacheFlush, Ttl: ttl}, Txt: text},
+		&dns.A{Hdr: dns.RR_Header{Name: "peer.local.", Rrtype: dns.TypeA, Class: dns.ClassINET | mdnsCacheFlush, Ttl: ttl}, A: net.ParseIP("192.0.2.20")},
+	}
+}
+
+func mdnsResponse(records ...dns.RR) *dns.Msg {
+	msg := &dns.Msg{}
+	msg.Response = true
+	msg.Authoritative = true
+	msg.Answer = records
+	return msg
+}
+
+func mdnsReceive(t *testing.T, e *mdnsResponder, msg *dns.Msg, from *net.UDPAddr) []packet {
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.receive(datagram{data: data, addr: from})
+}
+
+func TestMDNSAnswer(t *testing.T) {
+	service := &Service{UUID: "*answer.Request", Provider: Provider{Port: 8080}}
+
+	for _, c := range []struct {
+		name     string
+		ip       string
+		port     int
+		question dns.Question
+		answers  []uint16
+		unicast  bool
+	}{
+		{"browse", "192.0.2.1", mdnsPort, dns.Question{Name: mdnsServiceName, Qtype: dns.TypePTR, Qclass: dns.ClassINET}, []uint16{dns.TypePTR}, false},
+		{"unicast question", "192.0.2.1", mdnsPort, dns.Question{Name: mdnsServiceName, Qtype: dns.TypePTR, Qclass: dns.ClassINET | mdnsUnicastResponse}, []uint16{dns.TypePTR}, true},
+		{"legacy unicast", "192.0.2.1", 40000, dns.Question{Name: mdnsServiceName, Qtype: dns.TypePTR, Qclass: dns.ClassINET}, []uint16{dns.TypePTR}, true},
+		{"enumeration", "192.0.2.1", mdnsPort, dns.Question{Name: mdnsEnumerationName, Qtype: dns.TypePTR, Qclass: dns.ClassINET}, []uint16{dns.TypePTR}, false},
+		{"host", "192.0.2.1", mdnsPort, dns.Question{Name: mdnsHostname(), Qtype: dns.TypeA, Qclass: dns.ClassINET}, []uint16{dns.TypeA}, false},
+		{"host without ipv6", "192.0.2.1", mdnsPort, dns.Question{Name: mdnsHostname(), Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}, nil, false},
+		{"other service", "192.0.2.1", mdnsPort, dns.Question{Name: "_http._tcp.local.", Qtype: dns.TypePTR, Qclass: dns.ClassINET}, nil, false},
+		{"multicast from afar", "198.51.100.1", mdnsPort, dns.Question{Name: mdnsServiceName, Qtype: dns.TypePTR, Qclass: dns.ClassINET}, []uint16{dns.TypePTR}, false},
+		{"unicast question from afar", "198.51.100.1", mdnsPort, dns.Question{Name: mdnsServiceName, Qtype: dns.TypePTR, Qclass: dns.ClassINET | mdnsUnicastResponse}, nil, false},
+		{"legacy unicast from afar", "198.51.100.1", 40000, dns.Question{Name: mdnsEnumerationName, Qtype: dns.TypePTR, Qclass: dns.ClassINET}, nil, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			e := mdnsResponderFor(t, service)
+			from := &net.UDPAddr{IP: net.ParseIP(c.ip), Port: c.port}
+
+			query := &dns.Msg{}
+			query.Id = 1234
+			query.Question = []dns.Question{c.question}
+			packets := mdnsReceive(t, e, query, from)
+			if c.answers == nil {
+				if len(packets) != 0 {
+					t.Fatalf("answered with %d packets", len(packets))
+				}
+				return
+			}
+			if len(packets) != 1 {
+				t.Fatalf("answered with %d packets", len(packets))
+			}
+			if unicast := packets[0].to != nil; unicast != c.unicast || (unicast && packets[0].to != from) {
+				t.Fatalf("answered to %v", packets[0].to)
+			}
+
+			msg := &dns.Msg{}
+			if err := msg.Unpack(packets[0].data); err != nil {
+				t.Fatal(err)
+			}
+			var answers []uint16
+			for _, rr := range msg.Answer {
+				answers = append(answers, rr.Header().Rrtype)
+			}
+			if !reflect.DeepEqual(answers, c.answers) {
+				t.Fatalf("answers %v, want %v", answers, c.answers)
+			}
+
+			// Legacy resolvers get a conventional reply, without cache
+			// flushes and with short TTLs.
+			legacy := c.port != mdnsPort
+			if legacy != (msg.Id == query.Id && len(msg.Question) == 1) {
+				t.Fatalf("id %d with questions %v", msg.Id, msg.Question)
+			}
+			for _, rr := range append(msg.Answer, msg.Extra...) {
+				hdr := rr.Header()
+				if legacy && (hdr.Class&mdnsCacheFlush != 0 || hdr.Ttl > mdnsSeconds(mdnsLegacyTTL)) {
+					t.Fatalf("legacy reply with %v", rr)
+				}
+			}
+		})
+	}
+}
+
+func TestMDNSLearn(t *testing.T) {
+	own := func(uuid string, ttl uint32) *dns.Msg {
+		return mdnsResponse(mdnsRecords(mdnsServiceName, "instance."+mdnsServiceName, 8080, []string{"uuid=" + uuid, "fast"}, ttl)...)
+	}
+	pointer := func(ttl uint32) *dns.Msg {
+		return mdnsResponse(mdnsRecords(mdnsServiceName, "instance."+mdnsServiceName, 8080, nil, ttl)[0])
+	}
+
+	for _, c := range []struct {
+		name      string
+		types     []string
+		namespace string
+		// cached is learnt first, then forgotten by a browse elapsed later.
+		cached  *dns.Msg
+		elapsed time.Duration
+		msg     *dns.Msg
+		uuid    string
+		want    int
+		queries int
+	}{
+		{name: "announcement", msg: own("*learn.Request", 120), uuid: "*learn.Request", want: 1},
+		{name: "goodbye", cached: own("*goodbye.Request", 120), msg: own("*goodbye.Request", 0), uuid: "*goodbye.Request"},
+		{name: "without uuid", msg: mdnsResponse(mdnsRecords(mdnsServiceName, "instance."+mdnsServiceName, 8080, []string{"fast"}, 120)...), uuid: ""},
+		{name: "other namespace", namespace: "staging", msg: own("*namespace.Request", 120), uuid: "*namespace.Request"},
+		{
+			name:  "foreign service",
+			types: []string{"_http._tcp"},
+			msg:   mdnsResponse(mdnsRecords("_http._tcp.local.", "printer._http._tcp.local.", 80, []string{"path=/"}, 120)...),
+			uuid:  "_http._tcp",
+			want:  1,
+		},
+		{name: "foreign service not browsed", msg: mdnsResponse(mdnsRecords("_ipp._tcp.local.", "printer._ipp._tcp.local.", 631, nil, 120)...), uuid: "_ipp._tcp"},
+		{name: "cached pointer", cached: own("*cached.Request", 120), msg: pointer(120), uuid: "*cached.Request", want: 1},
+		{name: "expired pointer", cached: own("*expired.Request", 1), elapsed: 2 * time.Second, msg: pointer(120), uuid: "*expired.Request", want: 1, queries: 2},
+		{name: "pointer goodbye", cached: own("*pointer.Request", 120), msg: pointer(0), uuid: "*pointer.Request"},
+		{name: "unknown pointer", msg: pointer(120), queries: 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			e := mdnsResponderFor(t, nil, c.types...)
+			e.cfg.Namespace = c.namespace
+			from := &net.UDPAddr{IP: net.ParseIP("192.0.2.20"), Port: mdnsPort}
+			defer func() {
+				for _, service := range InstancesOfService(c.uuid) {
+					storeRemoteService(service, 0, false)
+				}
+			}()
+
+			if c.cached != nil {
+				mdnsReceive(t, e, c.cached, from)
+				if _, err := e.browse(time.Now().Add(c.elapsed)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			packets := mdnsReceive(t, e, c.msg, from)
+
+			if services := InstancesOfService(c.uuid); len(services) != c.want {
+				t.Fatalf("instances %+v, want %d", services, c.want)
+			} else if c.want > 0 && services[0].Provider.Host != "192.0.2.20" {
+				t.Fatalf("provider %+v", services[0].Provider)
+			}
+
+			queries := 0
+			for _, p := range packets {
+				msg := &dns.Msg{}
+				if err := msg.Unpack(p.data); err != nil {
+					t.Fatal(err)
+				}
+				queries += len(msg.Question)
+			}
+			if queries != c.queries {
+				t.Fatalf("asked %d questions, want %d", queries, c.queries)
+			}
+		})
+	}
+}
+
+// TestMDNSUnicastAnswerCapped has a legacy resolver ask for more services
+// than fit a conventional DNS reply.
+func TestMDNSUnicastAnswerCapped(t *testing.T) {
+	e := mdnsResponderFor(t, nil)
+	for i := 0; i < 32; i++ {
+		service := &Service{UUID: "*capped.Request", Provider: Provider{Port: uint16(1024 + i)}}
+		e.states[service] = &mdnsState{service: *service}
+	}
+
+	query := &dns.Msg{}
+	query.Question = []dns.Question{{Name: mdnsServiceName, Qtype: dns.TypePTR, Qclass: dns.ClassINET}}
+	packets := mdnsReceive(t, e, query, &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 40000})
+	if len(packets) != 1 || len(packets[0].data) > mdnsMaxUnicastSize {
+		t.Fatalf("answered with %d packets", len(packets))
+	}
+
+	// Unpacking flags truncated messages, having read what they hold.
+	msg := &dns.Msg{}
+	if err := msg.Unpack(packets[0].data); err != nil && err != dns.ErrTruncated {
+		t.Fatal(err)
+	}
+	if !msg.Truncated || len(msg.Answer) == 0 || len(msg.Answer) == 32 {
+		t.Fatalf("truncated %v with %d answers", msg.Truncated, len(msg.Answer))
+	}
+}
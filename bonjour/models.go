@@ -1,20 +1,83 @@
 package bonjour
 
+import (
+	"net"
+	"strconv"
+)
+
+const (
+	MetadataOS     = "os"
+	MetadataArch   = "arch"
+	MetadataHost   = "host"
+	MetadataLang   = "lang"
+	MetadataHealth = "health"
+	// MetadataNetwork carries the network of providers announced off tcp,
+	// which only udp ones are.
+	MetadataNetwork = "network"
+)
+
 type Service struct {
 	UUID     string
 	Provider Provider
-	Tags     [12]string
-	Metadata Metadata
+	// Addresses are further hosts the provider can be reached at, in order of
+	// preference after Provider. Their ports and networks are ignored in
+	// favour of its ones.
+	Addresses []Provider
+	Tags      []string
+	Metadata  map[string]string
 }
 
 type Provider struct {
 	Host string
+	Zone string
 	Port uint16
+	// Network is empty for tcp. Providers on udp are announced with
+	// MetadataNetwork, those on unix sockets or mem pipes come from local
+	// discovery or resolver files, with Host holding the socket path or pipe
+	// name.
+	Network string
 }
 
-type Metadata struct {
-	OS   string
-	Arch string
-	Host string
-	Lang string
+// Providers lists every address of the service, starting with Provider.
+func (e *Service) Providers() []Provider {
+	providers := make([]Provider, 0, 1+len(e.Addresses))
+	providers = append(providers, e.Provider)
+	for _, address := range e.Addresses {
+		address.Port = e.Provider.Port
+		address.Network = e.Provider.Network
+		if address != e.Provider {
+			providers = append(providers, address)
+		}
+	}
+	return providers
+}
+
+func (e Provider) Hostname() string {
+	if e.Zone == "" {
+		return e.Host
+	}
+	return e.Host + "%" + e.Zone
+}
+
+func (e Provider) Address() string {
+	address := net.JoinHostPort(e.Hostname(), strconv.Itoa(int(e.Port)))
+	switch e.Network {
+	case "":
+		return address
+
+	case "udp":
+		return e.Network + ":" + address
+
+	default:
+		return e.Network + ":" + e.Host
+	}
+}
+
+// decodeNetwork applies the network announced in the metadata of a remote
+// service to its provider. Only udp is taken, as the others are local to the
+// host announcing them.
+func (e *Service) decodeNetwork() {
+	if e.Metadata[MetadataNetwork] == "udp" {
+		e.Provider.Network = "udp"
+	}
 }
@@ -0,0 +1,231 @@
+package bonjour
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+type multicastConn struct {
+	*net.UDPConn
+	group        *net.UDPAddr
+	interfaces   []net.Interface
+	join         func(*net.Interface, net.Addr) error
+	setInterface func(*net.Interface) error
+}
+
+func network(group *net.UDPAddr) string {
+	if group.IP.To4() != nil {
+		return "udp4"
+	}
+	return "udp6"
+}
+
+func multicastInterfaces(group *net.UDPAddr, cfg *Config) ([]net.Interface, error) {
+	if len(cfg.Interfaces) > 0 || network(group) == "udp4" {
+		return cfg.Interfaces, nil
+	}
+
+	// Link-local IPv6 groups must be joined on every interface explicitly.
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaces []net.Interface
+	for _, ifi := range interfaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		ifaces = append(ifaces, ifi)
+	}
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("No Multicast Interface Available for %v", group)
+	}
+	return ifaces, nil
+}
+
+func newMulticastConn(conn *net.UDPConn, group *net.UDPAddr, interfaces []net.Interface, cfg *Config) (*multicastConn, error) {
+	e := &multicastConn{
+		UDPConn:    conn,
+		group:      group,
+		interfaces: interfaces,
+	}
+
+	if network(group) == "udp4" {
+		p := ipv4.NewPacketConn(conn)
+		if err := p.SetMulticastTTL(cfg.TTL); err != nil {
+			return nil, err
+		}
+		if err := p.SetMulticastLoopback(true); err != nil {
+			return nil, err
+		}
+		e.join = p.JoinGroup
+		e.setInterface = p.SetMulticastInterface
+	} else {
+		p := ipv6.NewPacketConn(conn)
+		if err := p.SetMulticastHopLimit(cfg.TTL); err != nil {
+			return nil, err
+		}
+		if err := p.SetMulticastLoopback(true); err != nil {
+			return nil, err
+		}
+		e.join = p.JoinGroup
+		e.setInterface = p.SetMulticastInterface
+	}
+
+	return e, nil
+}
+
+func listenMulticast(group *net.UDPAddr, cfg *Config) (*multicastConn, error) {
+	interfaces, err := multicastInterfaces(group, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(interfaces) == 0 {
+		conn, err := net.ListenMulticastUDP(network(group), nil, group)
+		if err != nil {
+			return nil, err
+		}
+		e, err := newMulticastConn(conn, group, interfaces, cfg)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return e, nil
+	}
+
+	// Interfaces that cannot join are skipped, unless none can.
+	var e *multicastConn
+	for i := range interfaces {
+		if e != nil {
+			if err := e.join(&interfaces[i], group); err != nil {
+				logInterface(&interfaces[i], err)
+			}
+			continue
+		}
+
+		conn, lerr := net.ListenMulticastUDP(network(group), &interfaces[i], group)
+		if lerr != nil {
+			logInterface(&interfaces[i], lerr)
+			err = lerr
+			continue
+		}
+		if e, err = newMulticastConn(conn, group, interfaces, cfg); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if e == nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func logInterface(ifi *net.Interface, err error) {
+	if loggingLevel != LogDisabled {
+		logger.Println(ifi.Name, err)
+	}
+}
+
+func dialMulticast(group *net.UDPAddr, cfg *Config) (*multicastConn, error) {
+	interfaces, err := multicastInterfaces(group, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP(network(group), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := newMulticastConn(conn, group, interfaces, cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *multicastConn) Broadcast(message []byte) error {
+	if len(e.interfaces) == 0 {
+		_, err := e.WriteToUDP(message, e.group)
+		return err
+	}
+
+	// Failing interfaces are skipped, so as not to silence the others, and
+	// only failing on all of them is an error.
+	var ret error
+	sent := false
+	for i := range e.interfaces {
+		err := e.setInterface(&e.interfaces[i])
+		if err == nil {
+			_, err = e.WriteToUDP(message, e.group)
+		}
+		if err != nil {
+			logInterface(&e.interfaces[i], err)
+			ret = err
+			continue
+		}
+		sent = true
+	}
+	if sent {
+		return nil
+	}
+	return ret
+}
+
+func receiveDatagrams(connections []*multicastConn, done <-chan struct{}) <-chan datagram {
+	ch := make(chan datagram)
+	for _, conn := range connections {
+		go func(conn *multicastConn) {
+			for {
+				buffer := make([]byte, datagramSize+1)
+				n, addr, err := conn.ReadFromUDP(buffer)
+				select {
+				case ch <- datagram{data: buffer[:n], addr: addr, err: err}:
+				case <-done:
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+	return ch
+}
+
+// sendPacket broadcasts p on every connection, failing only if none can,
+// or answers its sender on the connection of the same network.
+func sendPacket(connections []*multicastConn, p packet) error {
+	if p.to == nil {
+		var ret error
+		sent := false
+		for _, conn := range connections {
+			if err := conn.Broadcast(p.data); err != nil {
+				if loggingLevel != LogDisabled {
+					logger.Println(conn.group, err)
+				}
+				ret = err
+				continue
+			}
+			sent = true
+		}
+		if sent {
+			return nil
+		}
+		return ret
+	}
+
+	for _, conn := range connections {
+		if network(conn.group) == network(p.to) {
+			_, err := conn.WriteToUDP(p.data, p.to)
+			return err
+		}
+	}
+	return nil
+}
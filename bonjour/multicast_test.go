@@ -0,0 +1,49 @@
+package bonjour
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBroadcastSkipsFailingInterfaces(t *testing.T) {
+	group, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer group.Close()
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	bad := errors.New("bad interface")
+	e := &multicastConn{
+		UDPConn:    conn,
+		group:      group.LocalAddr().(*net.UDPAddr),
+		interfaces: []net.Interface{{Name: "bad"}, {Name: "good"}},
+		setInterface: func(ifi *net.Interface) error {
+			if ifi.Name == "bad" {
+				return bad
+			}
+			return nil
+		},
+	}
+
+	if err := e.Broadcast([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	group.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 16)
+	if n, _, err := group.ReadFromUDP(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("read %q, %v", buf[:n], err)
+	}
+
+	e.interfaces = e.interfaces[:1]
+	if err := e.Broadcast([]byte("hello")); err != bad {
+		t.Fatalf("expected %v, got %v", bad, err)
+	}
+}
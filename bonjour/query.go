@@ -0,0 +1,74 @@
+package bonjour
+
+import (
+	"context"
+	"time"
+)
+
+// Query asks providers of uuid, or of every service for the wildcard, to
+// announce themselves now rather than at their next interval.
+func Query(uuid string) error {
+	ensureStarted()
+
+	cfg, ok := currentConfig()
+	if !ok {
+		return ErrNotStarted
+	}
+
+	if cfg.Protocols&ProtocolMDNS != 0 {
+		select {
+		case mdnsQueries <- struct{}{}:
+		default:
+		}
+	}
+	if cfg.Protocols&ProtocolNative == 0 {
+		return nil
+	}
+
+	query, err := marshalQuery(uuid, cfg.Namespace, true)
+	if err != nil {
+		return err
+	}
+	enqueue(packet{data: query})
+	return nil
+}
+
+// WaitForService returns the known instances of uuid or, if there are none,
+// queries for them with backoff until one announces itself or ctx is done.
+// Looking up a missing service thus blocks until the deadline of ctx.
+func WaitForService(ctx context.Context, uuid string) ([]Service, error) {
+	if err := Query(uuid); err != nil {
+		return nil, err
+	}
+
+	interval := defaultQueryInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		remoteServicesMutex.RLock()
+		changed := remoteServicesChanged
+		remoteServicesMutex.RUnlock()
+
+		if instances := InstancesOfService(uuid); len(instances) > 0 {
+			return instances, nil
+		}
+
+		select {
+		case <-changed:
+
+		case <-timer.C:
+			if err := Query(uuid); err != nil {
+				return nil, err
+			}
+			interval *= 2
+			if interval > defaultMaxInterval {
+				interval = defaultMaxInterval
+			}
+			timer.Reset(interval)
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package bonjour
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAnnounceBackoff(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &announceState{}
+	now := time.Now()
+	for _, want := range []time.Duration{cfg.AnnounceInterval, 2 * cfg.AnnounceInterval, 4 * cfg.AnnounceInterval} {
+		ttl, ok := state.due(&cfg, now)
+		if !ok || state.interval != want || ttl != announcementTTLFor(&cfg, want) {
+			t.Fatalf("due = %v, %v with interval %v, want interval %v", ttl, ok, state.interval, want)
+		}
+		if _, ok := state.due(&cfg, now.Add(want-time.Millisecond)); ok {
+			t.Fatalf("due again before %v", want)
+		}
+		now = now.Add(want)
+	}
+
+	for i := 0; i < 16; i++ {
+		state.due(&cfg, state.next)
+	}
+	if state.interval != cfg.MaxAnnounceInterval {
+		t.Fatalf("interval %v, want %v", state.interval, cfg.MaxAnnounceInterval)
+	}
+}
+
+// TestQueryResponse answers a query the way the listener would, then takes
+// the answer in as an announcement for WaitForService to return.
+func TestQueryResponse(t *testing.T) {
+	runningMutex.Lock()
+	autoStart = false
+	cfg := DefaultConfig()
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	running = &discovery{config: cfg}
+	runningMutex.Unlock()
+	defer func() {
+		runningMutex.Lock()
+		running = nil
+		runningMutex.Unlock()
+	}()
+
+	service := &Service{
+		UUID:     "*query.Request",
+		Provider: Provider{Port: 8080},
+	}
+	registeredServicesMutex.Lock()
+	registeredServices[service] = &announceState{last: time.Now()}
+	registeredServicesMutex.Unlock()
+	defer func() {
+		registeredServicesMutex.Lock()
+		delete(registeredServices, service)
+		registeredServicesMutex.Unlock()
+	}()
+
+	addr := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 7), Port: ipv4Port}
+	answer := func(unicast bool) *packet {
+		query, err := marshalQuery(service.UUID, cfg.Namespace, unicast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receive(&cfg, datagram{data: query, addr: addr})
+
+		select {
+		case p := <-outbox:
+			return &p
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		}
+	}
+
+	// Multicast answers just announced are left out, unicast ones are not.
+	if p := answer(false); p != nil {
+		t.Fatalf("unexpected multicast answer to %v", p.to)
+	}
+	p := answer(true)
+	if p == nil || p.to != addr {
+		t.Fatalf("expected unicast answer to %v, got %+v", addr, p)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := WaitForService(ctx, service.UUID); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	for len(outbox) > 0 {
+		<-outbox
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		receive(&cfg, datagram{data: p.data, addr: addr})
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	services, err := WaitForService(ctx, service.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Provider.Port != 8080 {
+		t.Fatalf("unexpected services %+v", services)
+	}
+	storeRemoteService(services[0], 0, false)
+	for len(outbox) > 0 {
+		<-outbox
+	}
+}
@@ -0,0 +1,61 @@
+package bonjour
+
+import (
+	"time"
+)
+
+const (
+	rateLimit = 512
+	rateBurst = 1024
+)
+
+type bucket struct {
+	tokens    float64
+	timestamp time.Time
+}
+
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+}
+
+func newRateLimiter(rate float64, burst float64) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (e *rateLimiter) Allow(key string, now time.Time) bool {
+	b, ok := e.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens:    e.burst,
+			timestamp: now,
+		}
+		e.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.timestamp).Seconds() * e.rate
+	if b.tokens > e.burst {
+		b.tokens = e.burst
+	}
+	b.timestamp = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (e *rateLimiter) Prune(now time.Time) {
+	refill := time.Duration(e.burst / e.rate * float64(time.Second))
+	for key, b := range e.buckets {
+		if now.Sub(b.timestamp) > refill {
+			delete(e.buckets, key)
+		}
+	}
+}
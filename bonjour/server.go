@@ -1,6 +1,7 @@
 package bonjour
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -8,100 +9,345 @@ import (
 	"sync"
 	"time"
 
-	"github.com/golang/protobuf/proto"
-	model "github.com/t0rr3sp3dr0/middleair/proto"
 	"github.com/t0rr3sp3dr0/middleair/util"
 )
 
 var (
-	registeredServices      = make(map[*Service]*struct{})
-	registeredServicesMutex = &sync.RWMutex{}
+	registeredServices        = make(map[*Service]*announceState)
+	registeredServicesMutex   = &sync.RWMutex{}
+	registeredServicesChanged = make(chan struct{})
+	outbox                    = make(chan packet, outboxSize)
+
+	errOutboxFull = errors.New("outbox full, dropping packet")
 )
 
-func broadcasterLoop() (ret error) {
+type announceState struct {
+	next     time.Time
+	last     time.Time
+	interval time.Duration
+}
+
+type packet struct {
+	data []byte
+	to   *net.UDPAddr
+}
+
+func broadcasterLoop(cfg *Config, stop <-chan struct{}) (ret error) {
 	defer func() {
 		if r := recover(); r != nil {
 			ret = fmt.Errorf("%v", r)
 		}
 	}()
 
-	var connections []*net.UDPConn
-	for _, addr := range addresses {
-		conn, err := net.DialUDP(addr.Network(), nil, addr)
+	var connections []*multicastConn
+	for _, group := range cfg.groups() {
+		conn, err := dialMulticast(group, cfg)
 		if err != nil {
-			return err
+			ret = err
+			logger.Println(group, err)
+			continue
 		}
 		defer conn.Close()
 
 		connections = append(connections, conn)
 	}
+	if len(connections) == 0 {
+		return ret
+	}
+	ret = nil
 
-	for {
-		var once sync.Once
-		registeredServicesMutex.RLock()
-		defer once.Do(registeredServicesMutex.RUnlock)
-		for service := range registeredServices {
-			announcement := &model.ServiceAnnouncement{
-				Uuid: service.UUID,
-				Port: int32(service.Provider.Port),
-				Tags: append(service.Tags[:], service.Metadata.OS, service.Metadata.Arch, service.Metadata.Host, service.Metadata.Lang),
+	// Unicast responses to our queries arrive on the sending sockets.
+	done := make(chan struct{})
+	defer close(done)
+	for _, conn := range connections {
+		go func(conn *multicastConn) {
+			for {
+				buffer := make([]byte, datagramSize+1)
+				n, addr, err := conn.ReadFromUDP(buffer)
+				if err != nil {
+					return
+				}
+
+				select {
+				case <-done:
+					return
+				default:
+					receive(cfg, datagram{data: buffer[:n], addr: addr})
+				}
 			}
+		}(conn)
+	}
 
-			message, err := proto.Marshal(announcement)
+	send := func(p packet) error {
+		return sendPacket(connections, p)
+	}
+
+	query, err := marshalQuery(wildcardQuery, cfg.Namespace, true)
+	if err != nil {
+		return err
+	}
+	if err := send(packet{data: query}); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		changed := servicesChanged()
+		select {
+		case <-timer.C:
+			messages, next, err := dueAnnouncements(cfg, time.Now())
 			if err != nil {
 				return err
 			}
+			for _, message := range messages {
+				if err := send(packet{data: message}); err != nil {
+					return err
+				}
+			}
+			timer.Reset(next)
+
+		case <-changed:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(0)
+
+		case p := <-outbox:
+			if err := send(p); err != nil {
+				return err
+			}
 
-			for _, conn := range connections {
-				if _, err := conn.Write(message); err != nil {
+		case <-stop:
+			for _, service := range snapshotServices() {
+				message, err := marshalAnnouncement(&service, cfg, 0)
+				if err != nil {
+					return err
+				}
+				if err := send(packet{data: message}); err != nil {
 					return err
 				}
 			}
+			return nil
 		}
-		once.Do(registeredServicesMutex.RUnlock)
+	}
+}
 
-		time.Sleep(500 * time.Millisecond)
+func announcementTTLFor(cfg *Config, interval time.Duration) time.Duration {
+	ttl := 3 * interval
+	if ttl < cfg.Expiry {
+		ttl = cfg.Expiry
 	}
+	return ttl
 }
 
-func RegisterService(service *Service) error {
-	if len(service.UUID) > 256 {
-		return util.ErrPayloadTooLarge
+func dueAnnouncements(cfg *Config, now time.Time) ([][]byte, time.Duration, error) {
+	registeredServicesMutex.Lock()
+	defer registeredServicesMutex.Unlock()
+
+	var messages [][]byte
+	next := cfg.MaxAnnounceInterval
+	for service, state := range registeredServices {
+		if ttl, ok := state.due(cfg, now); ok {
+			message, err := marshalAnnouncement(service, cfg, ttl)
+			if err != nil {
+				return nil, 0, err
+			}
+			messages = append(messages, message)
+		}
+
+		if d := state.next.Sub(now); d < next {
+			next = d
+		}
 	}
-	if len(service.Provider.Host) > 256 {
-		return util.ErrPayloadTooLarge
+	return messages, next, nil
+}
+
+func (e *announceState) due(cfg *Config, now time.Time) (time.Duration, bool) {
+	if now.Before(e.next) {
+		return 0, false
+	}
+
+	e.interval *= 2
+	if e.interval == 0 {
+		e.interval = cfg.AnnounceInterval
+	}
+	if e.interval > cfg.MaxAnnounceInterval {
+		e.interval = cfg.MaxAnnounceInterval
+	}
+
+	e.last = now
+	e.next = now.Add(e.interval)
+	return announcementTTLFor(cfg, e.interval), true
+}
+
+func respond(cfg *Config, query string, unicast bool, addr *net.UDPAddr) {
+	now := time.Now()
+
+	var packets []packet
+	registeredServicesMutex.RLock()
+	for service, state := range registeredServices {
+		if query != wildcardQuery && query != service.UUID {
+			continue
+		}
+		// Skip multicast answers the group has just heard anyway.
+		if !unicast && now.Sub(state.last) < cfg.AnnounceInterval {
+			continue
+		}
+
+		interval := state.interval
+		if interval == 0 {
+			interval = cfg.AnnounceInterval
+		}
+		message, err := marshalAnnouncement(service, cfg, announcementTTLFor(cfg, interval))
+		if err != nil {
+			continue
+		}
+
+		p := packet{data: message}
+		if unicast {
+			p.to = addr
+		}
+		packets = append(packets, p)
 	}
-	for _, tag := range service.Tags {
-		if len(tag) > 256 {
-			return util.ErrPayloadTooLarge
+	registeredServicesMutex.RUnlock()
+
+	for _, p := range packets {
+		enqueue(p)
+	}
+}
+
+func enqueue(p packet) {
+	select {
+	case outbox <- p:
+	default:
+		if loggingLevel != LogDisabled {
+			logger.Println(errOutboxFull)
 		}
 	}
+}
+
+func servicesChanged() <-chan struct{} {
+	registeredServicesMutex.RLock()
+	defer registeredServicesMutex.RUnlock()
 
-	service.Metadata.OS = runtime.GOOS
-	service.Metadata.Arch = runtime.GOARCH
+	return registeredServicesChanged
+}
+
+func notifyServicesChanged() {
+	close(registeredServicesChanged)
+	registeredServicesChanged = make(chan struct{})
+}
+
+func snapshotServices() []Service {
+	registeredServicesMutex.RLock()
+	defer registeredServicesMutex.RUnlock()
+
+	services := make([]Service, 0, len(registeredServices))
+	for service := range registeredServices {
+		services = append(services, *service)
+	}
+	return services
+}
+
+func RegisterService(service *Service) error {
+	ensureStarted()
+	cfg, _ := currentConfig()
+	return registerService(service, &cfg)
+}
+
+func registerService(service *Service, cfg *Config) error {
+	if service.Metadata == nil {
+		service.Metadata = make(map[string]string)
+	}
+	setDefaultMetadata(service.Metadata, MetadataOS, runtime.GOOS)
+	setDefaultMetadata(service.Metadata, MetadataArch, runtime.GOARCH)
 	if hostname, err := os.Hostname(); err == nil {
-		service.Metadata.Host = hostname
-		if len(service.Metadata.Host) > 256 {
-			service.Metadata.Host = service.Metadata.Host[:256]
-		}
+		setDefaultMetadata(service.Metadata, MetadataHost, hostname)
 	}
 	if language, ok := os.LookupEnv("LANG"); ok {
-		service.Metadata.Lang = language
-		if len(service.Metadata.Lang) > 256 {
-			service.Metadata.Lang = service.Metadata.Lang[:256]
-		}
+		setDefaultMetadata(service.Metadata, MetadataLang, language)
+	}
+
+	if err := validateService(service); err != nil {
+		return err
+	}
+	if _, err := marshalAnnouncement(service, cfg, maxTTL); err != nil {
+		return err
+	}
+
+	registeredServicesMutex.Lock()
+	registeredServices[service] = &announceState{}
+	notifyServicesChanged()
+	registeredServicesMutex.Unlock()
+	return nil
+}
+
+func setDefaultMetadata(metadata map[string]string, key string, value string) {
+	if _, ok := metadata[key]; ok {
+		return
+	}
+	if len(value) > maxFieldSize {
+		value = value[:maxFieldSize]
+	}
+	metadata[key] = value
+}
+
+// SetMetadata changes a metadata entry of a registered service and announces
+// the change right away. The map is replaced rather than written to, as
+// announcements in flight may still be reading the old one.
+func SetMetadata(service *Service, key string, value string) error {
+	if len(key) > maxFieldSize || len(value) > maxFieldSize {
+		return util.ErrPayloadTooLarge
 	}
 
 	registeredServicesMutex.Lock()
 	defer registeredServicesMutex.Unlock()
 
-	registeredServices[service] = nil
+	metadata := make(map[string]string, len(service.Metadata)+1)
+	for k, v := range service.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	service.Metadata = metadata
+
+	if _, ok := registeredServices[service]; ok {
+		registeredServices[service] = &announceState{}
+		notifyServicesChanged()
+	}
 	return nil
 }
 
 func UnregisterService(service *Service) {
 	registeredServicesMutex.Lock()
-	defer registeredServicesMutex.Unlock()
-
+	_, ok := registeredServices[service]
 	delete(registeredServices, service)
+	goodbye := *service
+	for other := range registeredServices {
+		if other.UUID == service.UUID && other.Provider == service.Provider {
+			ok = false
+			break
+		}
+	}
+	notifyServicesChanged()
+	registeredServicesMutex.Unlock()
+
+	if !ok {
+		return
+	}
+	cfg, running := currentConfig()
+	if !running || cfg.Protocols&ProtocolNative == 0 {
+		return
+	}
+
+	message, err := marshalAnnouncement(&goodbye, &cfg, 0)
+	if err != nil {
+		logger.Println(goodbye.UUID, err)
+		return
+	}
+	enqueue(packet{data: message})
 }
@@ -0,0 +1,181 @@
+package bonjour
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+type EventType int
+
+const (
+	EventAdded EventType = iota + 1
+	EventUpdated
+	EventRemoved
+	// EventResync has no service. It follows dropped events, and comes
+	// before every instance known then is reported as added again.
+	EventResync
+)
+
+const (
+	watchQueueSize = 1024
+)
+
+var (
+	watchers = make(map[*watcher]struct{})
+
+	errWatchQueueFull = errors.New("watch queue full, resyncing")
+)
+
+type Event struct {
+	Type    EventType
+	Service Service
+}
+
+// Filter selects the instances a watch reports, a nil Filter selects all.
+type Filter func(*Service) bool
+
+type watcher struct {
+	filter  Filter
+	pending []Event
+	// overflowed is set once pending has been dropped, until the watch
+	// resyncs.
+	overflowed bool
+	signal     chan struct{}
+	mutex      *sync.Mutex
+}
+
+func (e EventType) String() string {
+	switch e {
+	case EventAdded:
+		return "Added"
+	case EventUpdated:
+		return "Updated"
+	case EventRemoved:
+		return "Removed"
+	case EventResync:
+		return "Resync"
+	default:
+		return "Unknown"
+	}
+}
+
+// Watch reports the instances known when it is called as added, then every
+// change to them until ctx is done, when the channel is closed. Events queue
+// up while the receiver lags behind. Once watchQueueSize of them are pending
+// they are dropped for EventResync, after which the receiver has to forget
+// what it knows of the instances. The filter runs on the goroutine of the
+// watch, so it may call into the package.
+func Watch(ctx context.Context, filter Filter) <-chan Event {
+	ensureStarted()
+
+	w := &watcher{
+		filter: filter,
+		signal: make(chan struct{}, 1),
+		mutex:  &sync.Mutex{},
+	}
+
+	remoteServicesMutex.Lock()
+	w.pending = known()
+	watchers[w] = struct{}{}
+	remoteServicesMutex.Unlock()
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		defer func() {
+			remoteServicesMutex.Lock()
+			delete(watchers, w)
+			remoteServicesMutex.Unlock()
+		}()
+
+		for {
+			w.mutex.Lock()
+			events := w.pending
+			overflowed := w.overflowed
+			w.pending = nil
+			w.mutex.Unlock()
+			if overflowed {
+				events = w.resync()
+			}
+
+			for _, event := range events {
+				if event.Type != EventResync && w.filter != nil && !w.filter(&event.Service) {
+					continue
+				}
+
+				select {
+				case ch <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-w.signal:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// publish must be called with remoteServicesMutex held.
+func publish(event Event) {
+	for w := range watchers {
+		w.push(event)
+	}
+}
+
+// known lists every instance known as added. It must be called with
+// remoteServicesMutex held.
+func known() []Event {
+	events := []Event{}
+	for _, services := range remoteServices {
+		for _, remote := range services {
+			events = append(events, Event{Type: EventAdded, Service: remote.service})
+		}
+	}
+	return events
+}
+
+// push queues event for the filter to be applied outside of any lock. A full
+// queue is dropped, as is everything after it until the watch resyncs.
+func (e *watcher) push(event Event) {
+	e.mutex.Lock()
+	if e.overflowed {
+		e.mutex.Unlock()
+		return
+	}
+	if len(e.pending) >= watchQueueSize {
+		e.pending = nil
+		e.overflowed = true
+		e.mutex.Unlock()
+		if loggingLevel != LogDisabled {
+			logger.Println(errWatchQueueFull)
+		}
+	} else {
+		e.pending = append(e.pending, event)
+		e.mutex.Unlock()
+	}
+
+	select {
+	case e.signal <- struct{}{}:
+	default:
+	}
+}
+
+// resync replaces whatever is pending with EventResync followed by every
+// instance known.
+func (e *watcher) resync() []Event {
+	remoteServicesMutex.Lock()
+	defer remoteServicesMutex.Unlock()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.pending = nil
+	e.overflowed = false
+	return append([]Event{{Type: EventResync}}, known()...)
+}
@@ -0,0 +1,151 @@
+package bonjour
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchDeduplicatesEvents(t *testing.T) {
+	runningMutex.Lock()
+	autoStart = false
+	runningMutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := Watch(ctx, func(service *Service) bool {
+		return service.UUID == "*demo.Request"
+	})
+
+	service := Service{
+		UUID:     "*demo.Request",
+		Provider: Provider{Host: "192.0.2.1", Port: 8080},
+	}
+	storeRemoteService(service, time.Minute, true)
+	storeRemoteService(service, time.Minute, true)
+	storeRemoteService(Service{UUID: "*demo.Other", Provider: service.Provider}, time.Minute, true)
+	service.Tags = []string{"fast"}
+	storeRemoteService(service, time.Minute, true)
+	storeRemoteService(service, 0, false)
+	storeRemoteService(service, 0, false)
+
+	for _, want := range []EventType{EventAdded, EventUpdated, EventRemoved} {
+		select {
+		case event := <-ch:
+			if event.Type != want || event.Service.UUID != service.UUID {
+				t.Fatalf("event = %v %+v, want %v", event.Type, event.Service, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event %v %+v", event.Type, event.Service)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestWatchFilterMayCallPackage(t *testing.T) {
+	runningMutex.Lock()
+	autoStart = false
+	runningMutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := Watch(ctx, func(service *Service) bool {
+		InstancesOfService(service.UUID)
+		return service.UUID == "*filter.Request"
+	})
+
+	service := Service{
+		UUID:     "*filter.Request",
+		Provider: Provider{Host: "192.0.2.2", Port: 8080},
+	}
+	storeRemoteService(service, time.Minute, true)
+	defer storeRemoteService(service, 0, false)
+
+	select {
+	case event := <-ch:
+		if event.Type != EventAdded {
+			t.Fatalf("unexpected event %v", event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("filter deadlocked")
+	}
+}
+
+func TestWatchQueueBounded(t *testing.T) {
+	w := &watcher{
+		signal: make(chan struct{}, 1),
+		mutex:  &sync.Mutex{},
+	}
+	for i := 0; i < watchQueueSize+10; i++ {
+		w.push(Event{Type: EventAdded})
+	}
+	if len(w.pending) != 0 || !w.overflowed {
+		t.Fatalf("%d events pending, overflowed %v", len(w.pending), w.overflowed)
+	}
+
+	events := w.resync()
+	if events[0].Type != EventResync || w.overflowed {
+		t.Fatalf("resynced with %v, overflowed %v", events[0].Type, w.overflowed)
+	}
+	for _, event := range events[1:] {
+		if event.Type != EventAdded {
+			t.Fatalf("resynced with %v", event.Type)
+		}
+	}
+}
+
+func TestWatchResyncsWhenLagging(t *testing.T) {
+	runningMutex.Lock()
+	autoStart = false
+	runningMutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := Watch(ctx, func(service *Service) bool {
+		return service.UUID == "*resync.Request"
+	})
+
+	// Nothing is received meanwhile, so the queue overflows.
+	n := watchQueueSize + 10
+	for i := 0; i < n; i++ {
+		service := Service{
+			UUID:     "*resync.Request",
+			Provider: Provider{Host: "192.0.2.3", Port: uint16(1024 + i)},
+		}
+		storeRemoteService(service, time.Minute, true)
+		defer storeRemoteService(service, 0, false)
+	}
+	// The watch stops before the instances are removed.
+	defer cancel()
+
+	receive := func() Event {
+		select {
+		case event := <-ch:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for events")
+			return Event{}
+		}
+	}
+	for receive().Type != EventResync {
+	}
+	for i := 0; i < n; i++ {
+		if event := receive(); event.Type != EventAdded {
+			t.Fatalf("resynced with %v", event.Type)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+)
+
+// Balancer orders the instances of a service in which a client tries them.
+type Balancer interface {
+	Balance(uuid string, instances []bonjour.Service) []bonjour.Service
+}
+
+// RoundRobin starts every call of a service one instance past the previous.
+type RoundRobin struct {
+	next  map[string]int
+	mutex *sync.Mutex
+}
+
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{
+		next:  make(map[string]int),
+		mutex: &sync.Mutex{},
+	}
+}
+
+func (e *RoundRobin) Balance(uuid string, instances []bonjour.Service) []bonjour.Service {
+	e.mutex.Lock()
+	i := e.next[uuid] % len(instances)
+	e.next[uuid] = i + 1
+	e.mutex.Unlock()
+
+	return append(append([]bonjour.Service{}, instances[i:]...), instances[:i]...)
+}
@@ -0,0 +1,294 @@
+package client
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/resolver"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// Codec encodes requests and decodes responses of typed invocations. It must
+// match the one of providers, which use protobuf.
+type Codec interface {
+	Marshal(proto.Message) ([]byte, error)
+	Unmarshal([]byte, proto.Message) error
+}
+
+// InvokeFunc performs an invocation of uuid, handing fn the type name and
+// encoded body of every response.
+type InvokeFunc func(uuid string, req []byte, options *Options, fn func(string, []byte)) error
+
+// Interceptor wraps the invocations of a client, carrying on with next.
+type Interceptor func(uuid string, req []byte, options *Options, fn func(string, []byte), next InvokeFunc) error
+
+// Config holds what a Client uses when options of calls leave it out. Zero
+// fields take the defaults: the local and bonjour resolvers, instances in the
+// order resolved, the default pool config, protobuf and the package logger.
+type Config struct {
+	Resolver    resolver.Resolver
+	Balancer    Balancer
+	Pool        PoolConfig
+	Credentials []byte
+	// PreSharedKey seals datagrams to providers on udp, which otherwise take
+	// a handshake over tcp first.
+	PreSharedKey []byte
+	// DialTimeout bounds connecting to a provider, handshake included, ten
+	// seconds by default.
+	DialTimeout  time.Duration
+	Codec        Codec
+	Logger       *log.Logger
+	Interceptors []Interceptor
+}
+
+// Client invokes services with its own configuration and connection pools,
+// so that parts of a program need not share the package-level ones.
+type Client struct {
+	cfg    Config
+	invoke InvokeFunc
+	mutex  *sync.RWMutex
+
+	pools      map[poolKey]*pool
+	poolsMutex *sync.Mutex
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.Resolver == nil {
+		cfg.Resolver = resolver.NewPriorityResolver(resolver.NewLocalResolver(), resolver.NewBonjourResolver(0))
+	}
+	if cfg.Pool == (PoolConfig{}) {
+		cfg.Pool = PoolConfig{
+			MaxIdle:     defaultMaxIdle,
+			IdleTimeout: defaultIdleTimeout,
+		}
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = &util.Mashaler{}
+	}
+	cfg.Interceptors = append([]Interceptor{}, cfg.Interceptors...)
+
+	e := &Client{
+		cfg:        cfg,
+		mutex:      &sync.RWMutex{},
+		pools:      make(map[poolKey]*pool),
+		poolsMutex: &sync.Mutex{},
+	}
+
+	e.invoke = e.invokeRaw
+	for i := len(cfg.Interceptors) - 1; i >= 0; i-- {
+		interceptor, next := cfg.Interceptors[i], e.invoke
+		e.invoke = func(uuid string, req []byte, options *Options, fn func(string, []byte)) error {
+			return interceptor(uuid, req, options, fn, next)
+		}
+	}
+	return e
+}
+
+func (e *Client) SetResolver(r resolver.Resolver) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.cfg.Resolver = r
+}
+
+// SetPoolConfig applies to pooled connections from then on.
+func (e *Client) SetPoolConfig(cfg PoolConfig) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.cfg.Pool = cfg
+}
+
+func (e *Client) poolConfig() PoolConfig {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.cfg.Pool
+}
+
+// Invoke sends req to a provider of its type and decodes the response into
+// res, or the response of every provider in turn when broadcasting.
+func (e *Client) Invoke(req proto.Message, res proto.Message, options *Options) error {
+	data, err := e.cfg.Codec.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var failure error
+	err = e.InvokeRaw(reflect.TypeOf(req).String(), data, options, func(typeName string, data []byte) {
+		if err := e.cfg.Codec.Unmarshal(data, res); err != nil {
+			failure = err
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return failure
+}
+
+// InvokeRaw is Invoke for callers without compiled types: req is the encoded
+// request of the service uuid, and fn is handed the type name and encoded
+// body of every response.
+func (e *Client) InvokeRaw(uuid string, req []byte, options *Options, fn func(string, []byte)) error {
+	return e.invoke(uuid, req, options, fn)
+}
+
+func (e *Client) invokeRaw(uuid string, req []byte, options *Options, fn func(string, []byte)) error {
+	options = e.setDefaults(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), options.DiscoveryTimeout)
+	defer cancel()
+
+	instances, err := options.Resolver.Resolve(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return util.ErrNotFound
+	}
+	if e.cfg.Balancer != nil {
+		instances = e.cfg.Balancer.Balance(uuid, instances)
+	}
+
+	call := func(proxy *ClientProxy) error {
+		if options.OneWay {
+			return proxy.NotifyRaw(uuid, req)
+		}
+
+		typeName, data, err := proxy.InvokeRaw(uuid, req)
+		if err != nil {
+			return err
+		}
+
+		fn(typeName, data)
+		return nil
+	}
+
+	b := false
+	var failure error
+	for _, instance := range instances {
+		if !healthy(&instance) || (options.Prober != nil && !options.Prober.Healthy(&instance)) {
+			continue
+		}
+
+		if len(options.Tags) > 0 {
+			matches := 0
+		loop:
+			for _, localTag := range options.Tags {
+				for _, remoteTag := range remoteTags(&instance) {
+					if remoteTag == localTag {
+						matches++
+						if !options.StrictMatch {
+							break loop
+						}
+						continue loop
+					}
+				}
+			}
+			if matches == 0 || (options.StrictMatch && matches < len(options.Tags)) {
+				continue
+			}
+		}
+
+		if options.Persistent {
+			err = e.poolFor(instance.Provider, options.Credentials).do(&instance, call)
+		} else {
+			var proxy *ClientProxy
+			if proxy, err = e.dial(&instance, options.Credentials); err == nil {
+				err = call(proxy)
+				proxy.Close()
+			}
+		}
+		if err != nil {
+			e.log(err)
+			if _, ok := err.(*util.Error); ok {
+				failure = err
+			}
+			continue
+		}
+
+		if !options.Broadcast {
+			return nil
+		}
+		b = true
+	}
+
+	if !b {
+		// Errors answered by providers say more than their absence.
+		if failure != nil {
+			return failure
+		}
+		return util.ErrServiceUnavailable
+	}
+	return nil
+}
+
+// setDefaults returns a copy of options, which may be shared between calls,
+// with its zero values replaced by the defaults.
+func (e *Client) setDefaults(options *Options) *Options {
+	if options == nil {
+		options = &Options{}
+	}
+	copied := *options
+	options = &copied
+	if options.Tags == nil {
+		options.Tags = []string{}
+	}
+	if options.Credentials == nil {
+		options.Credentials = append([]byte{}, e.cfg.Credentials...)
+	}
+	if options.DiscoveryTimeout == 0 {
+		options.DiscoveryTimeout = defaultDiscoveryTimeout
+	}
+	if options.Resolver == nil {
+		e.mutex.RLock()
+		options.Resolver = e.cfg.Resolver
+		e.mutex.RUnlock()
+	}
+	return options
+}
+
+// dial connects to the addresses of the instance in order until one accepts.
+func (e *Client) dial(instance *bonjour.Service, credentials []byte) (*ClientProxy, error) {
+	var err error
+	for _, provider := range instance.Providers() {
+		protocol := provider.Network
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		var proxy *ClientProxy
+		proxy, err = NewClientProxy(util.Options{
+			Host:         provider.Hostname(),
+			Port:         provider.Port,
+			Protocol:     protocol,
+			Credentials:  credentials,
+			PreSharedKey: e.cfg.PreSharedKey,
+			DialTimeout:  e.cfg.DialTimeout,
+		})
+		if err == nil {
+			return proxy, nil
+		}
+
+		e.log(provider.Address(), err)
+	}
+	return nil, err
+}
+
+func (e *Client) log(v ...interface{}) {
+	if e.cfg.Logger != nil {
+		e.cfg.Logger.Println(v...)
+		return
+	}
+	if loggingLevel&LogEnabled != LogDisabled {
+		logger.Println(v...)
+	}
+}
@@ -1,18 +1,23 @@
 package client
 
 import (
-	"reflect"
-	"sync"
+	"fmt"
+	"net"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/resolver"
 	"github.com/t0rr3sp3dr0/middleair/util"
 )
 
-var (
-	proxies      = make(map[bonjour.Provider]*ClientProxy)
-	proxiesMutex = &sync.RWMutex{}
-)
+// defaultClient serves the package-level functions. It is set up in init,
+// as probing health goes through it too.
+var defaultClient *Client
+
+func init() {
+	defaultClient = NewClient(Config{})
+}
 
 type ClientProxy struct {
 	requestor *Requestor
@@ -37,124 +42,72 @@ func (e *ClientProxy) Invoke(req proto.Message, res proto.Message) error {
 	return e.requestor.Invoke(req, res)
 }
 
+func (e *ClientProxy) InvokeRaw(uuid string, req []byte) (string, []byte, error) {
+	return e.requestor.InvokeRaw(uuid, req)
+}
+
+func (e *ClientProxy) Notify(req proto.Message) error {
+	return e.requestor.Notify(req)
+}
+
+func (e *ClientProxy) NotifyRaw(uuid string, req []byte) error {
+	return e.requestor.NotifyRaw(uuid, req)
+}
+
+// SetDeadline bounds the invocations made until it is reset with the zero
+// time.
+func (e *ClientProxy) SetDeadline(t time.Time) error {
+	return e.requestor.SetDeadline(t)
+}
+
+func (e *ClientProxy) LocalAddr() net.Addr {
+	return e.requestor.LocalAddr()
+}
+
+const (
+	defaultDiscoveryTimeout = 2 * time.Second
+	defaultDialTimeout      = 10 * time.Second
+)
+
 type Options struct {
 	Tags        []string
 	StrictMatch bool
 	Broadcast   bool
 	Persistent  bool
+	// OneWay sends requests without waiting for their responses, which is
+	// only possible with providers on udp.
+	OneWay      bool
 	Credentials []byte
+	// DiscoveryTimeout bounds how long invocations wait for providers to be
+	// discovered. Invoking a service nobody provides blocks for all of it,
+	// two seconds by default, before failing with util.ErrNotFound.
+	DiscoveryTimeout time.Duration
+	Resolver         resolver.Resolver
+	Prober           *Prober
 }
 
-func Invoke(req proto.Message, res proto.Message, options *Options) error {
-	if options == nil {
-		options = &Options{}
-	}
-	if options.Tags == nil {
-		options.Tags = []string{}
-	}
-	if options.Credentials == nil {
-		options.Credentials = []byte{}
-	}
-
-	instances := bonjour.InstancesOfService(reflect.TypeOf(req).String())
-	if len(instances) == 0 {
-		return util.ErrNotFound
-	}
-
-	b := false
-	for _, instance := range instances {
-		if len(options.Tags) > 0 {
-			matches := 0
-		loop:
-			for _, localTag := range options.Tags {
-				for _, remoteTag := range append([]string{
-					instance.Metadata.OS,
-					instance.Metadata.Arch,
-					instance.Metadata.Host,
-					instance.Metadata.Lang,
-				}, instance.Tags[:]...) {
-					if remoteTag == localTag {
-						matches++
-						if !options.StrictMatch {
-							break loop
-						}
-						continue loop
-					}
-				}
-			}
-			if matches == 0 || (options.StrictMatch && matches < len(options.Tags)) {
-				continue
-			}
-		}
-
-		proxy, ok := func(provider *bonjour.Provider) (*ClientProxy, bool) {
-			if !options.Persistent {
-				return nil, false
-			}
-
-			proxiesMutex.RLock()
-			proxy, ok := proxies[*provider]
-			proxiesMutex.RUnlock()
-
-			return proxy, ok
-		}(&instance.Provider)
-		if !ok {
-			clientProxy, err := NewClientProxy(util.Options{
-				Host:        instance.Provider.Host,
-				Port:        instance.Provider.Port,
-				Protocol:    "tcp",
-				Credentials: options.Credentials,
-			})
-			if err != nil {
-				if loggingLevel&LogEnabled != LogDisabled {
-					logger.Println(err)
-				}
-				continue
-			}
-
-			if options.Persistent {
-				proxiesMutex.Lock()
-				proxies[instance.Provider] = clientProxy
-				proxiesMutex.Unlock()
-			}
-
-			proxy = clientProxy
-		}
-		if !options.Persistent {
-			defer proxy.Close()
-		}
-
-		if err := proxy.Invoke(req, res); err != nil {
-			if loggingLevel&LogEnabled != LogDisabled {
-				logger.Println(err)
-			}
-			continue
-		}
-
-		if !options.Broadcast {
-			return nil
-		}
-		b = true
-	}
-
-	if !b {
-		return util.ErrServiceUnavailable
-	}
-	return nil
+// SetResolver replaces the resolver of the default client, which the
+// package-level functions use.
+func SetResolver(r resolver.Resolver) {
+	defaultClient.SetResolver(r)
 }
 
-func ClosePersistentConns() (errs []error) {
-	proxiesMutex.Lock()
-	defer proxiesMutex.Unlock()
+func Invoke(req proto.Message, res proto.Message, options *Options) error {
+	return defaultClient.Invoke(req, res, options)
+}
 
-	for provider, proxy := range proxies {
-		if err := proxy.Close(); err != nil {
-			errs = append(errs, err)
-			continue
-		}
+// InvokeRaw is Invoke for callers without compiled types: req is the encoded
+// request of the service uuid, and fn is handed the type name and encoded
+// body of every response.
+func InvokeRaw(uuid string, req []byte, options *Options, fn func(string, []byte)) error {
+	return defaultClient.InvokeRaw(uuid, req, options, fn)
+}
 
-		delete(proxies, provider)
+func remoteTags(instance *bonjour.Service) []string {
+	tags := make([]string, 0, len(instance.Tags)+2*len(instance.Metadata))
+	tags = append(tags, instance.Tags...)
+	for k, v := range instance.Metadata {
+		tags = append(tags, v, fmt.Sprintf("%s=%s", k, v))
 	}
-
-	return errs
+	return tags
 }
@@ -1,32 +1,41 @@
 package client
 
 import (
-	"fmt"
 	"net"
+	"time"
 
 	"github.com/t0rr3sp3dr0/middleair/crypto"
 	"github.com/t0rr3sp3dr0/middleair/util"
 )
 
 type ClientRequestHandler struct {
-	options util.Options
-	netConn crypto.SecureConn
+	options  util.Options
+	netConn  crypto.SecureConn
+	datagram *datagramConn
+	// unsent tells whether the last request failed to be sent, so that it
+	// is safe to send again.
+	unsent bool
 }
 
 func NewClientRequestHandler(options util.Options) (*ClientRequestHandler, error) {
 	switch options.Protocol {
 	case "udp":
-	case "tcp":
+		return newDatagramRequestHandler(options)
+	case "tcp", "unix", "mem":
 	default:
 		return nil, util.ErrMethodNotAllowed
 	}
 
-	conn, err := net.Dial(options.Protocol, fmt.Sprintf("%s:%d", options.Host, options.Port))
+	conn, err := util.Dial(options)
 	if err != nil {
 		return nil, err
 	}
+	if options.DialTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(options.DialTimeout))
+	}
 	secureConn, err := crypto.NewSecureConn(conn)
 	if err != nil {
+		defer conn.Close()
 		return nil, err
 	}
 
@@ -55,6 +64,11 @@ func NewClientRequestHandler(options util.Options) (*ClientRequestHandler, error
 		}
 	}
 
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		defer secureConn.Close()
+		return nil, err
+	}
+
 	e := &ClientRequestHandler{
 		options: options,
 		netConn: *secureConn,
@@ -64,14 +78,71 @@ func NewClientRequestHandler(options util.Options) (*ClientRequestHandler, error
 }
 
 func (e *ClientRequestHandler) Close() error {
+	if e.datagram != nil {
+		return e.datagram.Close()
+	}
 	return e.netConn.Close()
 }
 
+// SetDeadline bounds sending requests and receiving responses until reset
+// with the zero time.
+func (e *ClientRequestHandler) SetDeadline(t time.Time) error {
+	if e.datagram != nil {
+		e.datagram.deadline = t
+		return e.datagram.conn.SetWriteDeadline(t)
+	}
+	return e.netConn.SetDeadline(t)
+}
+
+func (e *ClientRequestHandler) LocalAddr() net.Addr {
+	if e.datagram != nil {
+		return e.datagram.conn.LocalAddr()
+	}
+	return e.netConn.LocalAddr()
+}
+
+// alive peeks at the connection for a moment to tell whether the peer has
+// closed it. Nothing is due from servers between requests, so anything read
+// counts as broken too.
+func (e *ClientRequestHandler) alive() bool {
+	if e.datagram != nil {
+		return e.datagram.alive()
+	}
+
+	if err := e.netConn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+	defer e.netConn.SetReadDeadline(time.Time{})
+
+	_, err := e.netConn.Read(make([]byte, 1))
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
 func (e *ClientRequestHandler) Send(message []byte) error {
-	_, err := e.netConn.WriteData(message)
+	var err error
+	if e.datagram != nil {
+		err = e.datagram.send(message, 0)
+	} else {
+		_, err = e.netConn.WriteData(message)
+	}
+	e.unsent = err != nil
 	return err
 }
 
+// SendOneWay sends a request the server is not to answer, which only
+// datagrams can carry.
+func (e *ClientRequestHandler) SendOneWay(message []byte) error {
+	if e.datagram == nil {
+		return util.ErrMethodNotAllowed
+	}
+	return e.datagram.send(message, util.OneWay)
+}
+
 func (e *ClientRequestHandler) Receive() ([]byte, error) {
+	if e.datagram != nil {
+		return e.datagram.receive()
+	}
+
 	return e.netConn.ReadData()
 }
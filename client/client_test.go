@@ -0,0 +1,47 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/resolver"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+func TestClientBalancerAndInterceptors(t *testing.T) {
+	serve(t, &lookupServer{revision: 100}, util.Options{Host: "first", Protocol: "mem"})
+	serve(t, &lookupServer{revision: 200}, util.Options{Host: "second", Protocol: "mem"})
+
+	var order []string
+	intercept := func(name string) Interceptor {
+		return func(uuid string, req []byte, options *Options, fn func(string, []byte), next InvokeFunc) error {
+			order = append(order, name)
+			return next(uuid, req, options, fn)
+		}
+	}
+	c := NewClient(Config{
+		Resolver: resolver.NewStaticResolver(map[string][]bonjour.Service{
+			"*": {
+				{Provider: bonjour.Provider{Host: "first", Network: "mem"}},
+				{Provider: bonjour.Provider{Host: "second", Network: "mem"}},
+			},
+		}),
+		Balancer:     NewRoundRobin(),
+		Interceptors: []Interceptor{intercept("outer"), intercept("inner")},
+	})
+	defer c.Close()
+
+	for _, want := range []uint64{100, 200, 100} {
+		res := &model.LookupResponse{}
+		if err := c.Invoke(&model.LookupRequest{}, res, nil); err != nil {
+			t.Fatal(err)
+		}
+		if res.Revision != want {
+			t.Fatalf("revision %d, want %d", res.Revision, want)
+		}
+	}
+	if len(order) != 6 || order[0] != "outer" || order[1] != "inner" {
+		t.Fatalf("intercepted in order %q", order)
+	}
+}
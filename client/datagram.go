@@ -0,0 +1,137 @@
+package client
+
+import (
+	"net"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/crypto"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+const (
+	// Datagrams get lost, so responses are not waited for longer than this.
+	datagramTimeout = 5 * time.Second
+	maxDatagramSize = 1 << 16
+)
+
+// datagramConn exchanges sealed datagrams with a server over udp. Unless
+// keyed beforehand, it holds a connection over tcp whose handshake keys them,
+// for as long as the server is to accept them.
+type datagramConn struct {
+	conn    net.Conn
+	session *ClientRequestHandler
+	cipher  *crypto.DatagramCipher
+	keyring *crypto.Keyring
+	mtu     int
+	id      uint64
+	// deadline, if set, cuts waiting for responses short.
+	deadline time.Time
+}
+
+func newDatagramRequestHandler(options util.Options) (*ClientRequestHandler, error) {
+	if options.MTU == 0 {
+		options.MTU = util.DefaultMTU
+	}
+
+	var session *ClientRequestHandler
+	var c *crypto.DatagramCipher
+	var err error
+	if options.PreSharedKey != nil {
+		c, err = crypto.NewDatagramCipher(options.PreSharedKey, crypto.ToServer)
+	} else {
+		stream := options
+		stream.Protocol = "tcp"
+		if session, err = NewClientRequestHandler(stream); err == nil {
+			if c, err = session.netConn.DatagramCipher(crypto.ToServer); err != nil {
+				session.Close()
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := util.Dial(options)
+	if err != nil {
+		if session != nil {
+			session.Close()
+		}
+		return nil, err
+	}
+
+	keyring := crypto.NewKeyring()
+	keyring.Add(c)
+
+	return &ClientRequestHandler{
+		options: options,
+		datagram: &datagramConn{
+			conn:    conn,
+			session: session,
+			cipher:  c,
+			keyring: keyring,
+			mtu:     options.MTU,
+		},
+	}, nil
+}
+
+func (e *datagramConn) Close() error {
+	err := e.conn.Close()
+	if e.session != nil {
+		if serr := e.session.Close(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+func (e *datagramConn) alive() bool {
+	return e.session == nil || e.session.alive()
+}
+
+func (e *datagramConn) send(message []byte, flags byte) error {
+	e.id++
+	datagram, err := e.cipher.Seal(util.Datagram(flags, e.id, message))
+	if err != nil {
+		return err
+	}
+	if len(datagram) > e.mtu {
+		return util.ErrPayloadTooLarge
+	}
+
+	_, err = e.conn.Write(datagram)
+	return err
+}
+
+// receive returns the response to the last request sent, dropping whatever
+// else arrives meanwhile, such as late responses to earlier ones.
+func (e *datagramConn) receive() ([]byte, error) {
+	deadline := time.Now().Add(datagramTimeout)
+	if !e.deadline.IsZero() && e.deadline.Before(deadline) {
+		deadline = e.deadline
+	}
+	if err := e.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	defer e.conn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, err := e.conn.Read(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return nil, util.ErrGatewayTimeout
+			}
+			return nil, err
+		}
+
+		data, _, err := e.keyring.Open(buf[:n])
+		if err != nil {
+			continue
+		}
+		_, id, message, err := util.ParseDatagram(data)
+		if err != nil || id != e.id {
+			continue
+		}
+		return message, nil
+	}
+}
@@ -0,0 +1,325 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/util"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// DescriptorSource describes the messages of services for callers without
+// compiled types. Responses are named by the type name they arrive with.
+type DescriptorSource interface {
+	Request(uuid string) (protoreflect.MessageDescriptor, error)
+	Response(uuid string, typeName string) (protoreflect.MessageDescriptor, error)
+}
+
+// DynamicClient invokes services from descriptors alone, taking and
+// returning dynamic messages or their JSON form.
+type DynamicClient struct {
+	client  *Client
+	source  DescriptorSource
+	options *Options
+}
+
+// NewDynamicClient invokes through the default client.
+func NewDynamicClient(source DescriptorSource, options *Options) *DynamicClient {
+	return defaultClient.NewDynamicClient(source, options)
+}
+
+func (e *Client) NewDynamicClient(source DescriptorSource, options *Options) *DynamicClient {
+	return &DynamicClient{
+		client:  e,
+		source:  source,
+		options: options,
+	}
+}
+
+func (e *DynamicClient) NewRequest(uuid string) (*dynamicpb.Message, error) {
+	md, err := e.source.Request(uuid)
+	if err != nil {
+		return nil, err
+	}
+	return dynamicpb.NewMessage(md), nil
+}
+
+// Invoke returns a response per provider invoked, which is more than one only
+// when broadcasting.
+func (e *DynamicClient) Invoke(uuid string, req proto.Message) ([]*dynamicpb.Message, error) {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []*dynamicpb.Message
+	var failure error
+	err = e.client.InvokeRaw(uuid, data, e.options, func(typeName string, data []byte) {
+		md, err := e.source.Response(uuid, typeName)
+		if err != nil {
+			failure = err
+			return
+		}
+
+		res := dynamicpb.NewMessage(md)
+		if err := proto.Unmarshal(data, res); err != nil {
+			failure = err
+			return
+		}
+		responses = append(responses, res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return responses, nil
+}
+
+func (e *DynamicClient) InvokeJSON(uuid string, body []byte) ([][]byte, error) {
+	req, err := e.NewRequest(uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := protojson.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+
+	responses, err := e.Invoke(uuid, req)
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := make([][]byte, 0, len(responses))
+	for _, res := range responses {
+		data, err := protojson.Marshal(res)
+		if err != nil {
+			return nil, err
+		}
+		bodies = append(bodies, data)
+	}
+	return bodies, nil
+}
+
+// protoName maps the Go type name used as UUID to the protobuf name, which
+// agree as long as Go packages are named after the proto ones.
+func protoName(typeName string) protoreflect.FullName {
+	return protoreflect.FullName(strings.TrimPrefix(typeName, "*"))
+}
+
+func findMessage(files *protoregistry.Files, name protoreflect.FullName) (protoreflect.MessageDescriptor, error) {
+	d, err := files.FindDescriptorByName(name)
+	if err == protoregistry.NotFound {
+		return nil, util.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s: Not a Message", name)
+	}
+	return md, nil
+}
+
+func newFiles(descriptors [][]byte) (*protoregistry.Files, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, data := range descriptors {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(data, fd); err != nil {
+			return nil, err
+		}
+		set.File = append(set.File, fd)
+	}
+	return protodesc.NewFiles(set)
+}
+
+type fileSource struct {
+	files *protoregistry.Files
+}
+
+// NewFileSource loads a FileDescriptorSet, as written by protoc with
+// --descriptor_set_out and --include_imports.
+func NewFileSource(path string) (DescriptorSource, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, err
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSource{files: files}, nil
+}
+
+func (e *fileSource) Request(uuid string) (protoreflect.MessageDescriptor, error) {
+	return findMessage(e.files, protoName(uuid))
+}
+
+func (e *fileSource) Response(uuid string, typeName string) (protoreflect.MessageDescriptor, error) {
+	return findMessage(e.files, protoName(typeName))
+}
+
+const (
+	reflectionTTL = 5 * time.Minute
+)
+
+type reflectedService struct {
+	service *model.ReflectedService
+	files   *protoregistry.Files
+	expiry  time.Time
+}
+
+// reflection is a call to reflect in flight, which callers asking for the
+// same service wait on rather than reflecting again.
+type reflection struct {
+	done    chan struct{}
+	service *reflectedService
+	err     error
+}
+
+// ReflectionSource asks providers for the descriptors of their services
+// through reflection, keeping what they report for five minutes.
+type ReflectionSource struct {
+	client   *Client
+	options  *Options
+	services map[string]*reflectedService
+	inflight map[string]*reflection
+	mutex    *sync.Mutex
+}
+
+// NewReflectionSource reflects through the default client.
+func NewReflectionSource(options *Options) *ReflectionSource {
+	return defaultClient.NewReflectionSource(options)
+}
+
+func (e *Client) NewReflectionSource(options *Options) *ReflectionSource {
+	return &ReflectionSource{
+		client:   e,
+		options:  options,
+		services: make(map[string]*reflectedService),
+		inflight: make(map[string]*reflection),
+		mutex:    &sync.Mutex{},
+	}
+}
+
+// Invalidate forgets what was reflected about uuid, so that providers are
+// asked again next time.
+func (e *ReflectionSource) Invalidate(uuid string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	delete(e.services, uuid)
+}
+
+func (e *ReflectionSource) Request(uuid string) (protoreflect.MessageDescriptor, error) {
+	s, err := e.reflect(uuid)
+	if err != nil {
+		return nil, err
+	}
+	return findMessage(s.files, protoreflect.FullName(s.service.RequestType))
+}
+
+func (e *ReflectionSource) Response(uuid string, typeName string) (protoreflect.MessageDescriptor, error) {
+	s, err := e.reflect(uuid)
+	if err != nil {
+		return nil, err
+	}
+	if s.service.ResponseType != "" {
+		return findMessage(s.files, protoreflect.FullName(s.service.ResponseType))
+	}
+	return findMessage(s.files, protoName(typeName))
+}
+
+// Service returns what a provider of uuid reported about it.
+func (e *ReflectionSource) Service(uuid string) (*model.ReflectedService, error) {
+	s, err := e.reflect(uuid)
+	if err != nil {
+		return nil, err
+	}
+	return s.service, nil
+}
+
+func (e *ReflectionSource) reflect(uuid string) (*reflectedService, error) {
+	e.mutex.Lock()
+	if s, ok := e.services[uuid]; ok && time.Now().Before(s.expiry) {
+		e.mutex.Unlock()
+		return s, nil
+	}
+	if r, ok := e.inflight[uuid]; ok {
+		e.mutex.Unlock()
+		<-r.done
+		return r.service, r.err
+	}
+	r := &reflection{done: make(chan struct{})}
+	e.inflight[uuid] = r
+	e.mutex.Unlock()
+
+	r.service, r.err = e.reflectNow(uuid)
+
+	e.mutex.Lock()
+	delete(e.inflight, uuid)
+	if r.err == nil {
+		e.services[uuid] = r.service
+	}
+	e.mutex.Unlock()
+	close(r.done)
+
+	return r.service, r.err
+}
+
+func (e *ReflectionSource) reflectNow(uuid string) (*reflectedService, error) {
+	options := e.client.setDefaults(e.options)
+	ctx, cancel := context.WithTimeout(context.Background(), options.DiscoveryTimeout)
+	defer cancel()
+
+	instances, err := options.Resolver.Resolve(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fmt.Errorf("%s: No Healthy Instance", uuid)
+	for _, instance := range instances {
+		if !healthy(&instance) {
+			continue
+		}
+
+		var response *model.ServerReflectionResponse
+		response, err = e.client.Reflect(&instance, uuid, options.Credentials)
+		if err != nil {
+			continue
+		}
+
+		s := &reflectedService{expiry: time.Now().Add(reflectionTTL)}
+		for _, service := range response.Services {
+			if service.Uuid == uuid {
+				s.service = service
+			}
+		}
+		if s.service == nil {
+			return nil, util.ErrNotFound
+		}
+		if s.files, err = newFiles(response.FileDescriptors); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, err
+}
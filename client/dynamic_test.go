@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/resolver"
+	"github.com/t0rr3sp3dr0/middleair/server"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// lookupServer answers lookups with revision plus the length of their uuid.
+type lookupServer struct {
+	revision uint64
+}
+
+func (e *lookupServer) Registry() []*server.Service {
+	return []*server.Service{
+		&server.Service{
+			Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+			Handle: func(message proto.Message) (proto.Message, error) {
+				return &model.LookupResponse{Revision: e.revision + uint64(len(message.(*model.LookupRequest).Uuid))}, nil
+			},
+			Response: reflect.TypeOf((*model.LookupResponse)(nil)),
+		},
+	}
+}
+
+func (e *lookupServer) Tags() []string {
+	return []string{}
+}
+
+// serve runs sp until the test ends, once local discovery knows of it if on
+// a local transport.
+func serve(t *testing.T, sp server.ServerProxy, options util.Options) *server.Server {
+	s, err := server.NewServer(sp, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	t.Cleanup(func() { s.Close() })
+
+	if options.Protocol == "mem" {
+		uuid := reflect.TypeOf((*model.LookupRequest)(nil)).String()
+		for {
+			if _, err := resolver.NewLocalResolver().Resolve(context.Background(), uuid); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	return s
+}
+
+func TestDynamicClient(t *testing.T) {
+	serve(t, &lookupServer{}, util.Options{Host: "dynamic", Protocol: "mem"})
+
+	calls := 0
+	c := NewClient(Config{
+		Resolver: resolver.NewLocalResolver(),
+		Interceptors: []Interceptor{
+			func(uuid string, req []byte, options *Options, fn func(string, []byte), next InvokeFunc) error {
+				calls++
+				return next(uuid, req, options, fn)
+			},
+		},
+	})
+	defer c.Close()
+
+	dc := c.NewDynamicClient(c.NewReflectionSource(nil), nil)
+	responses, err := dc.InvokeJSON("*proto.LookupRequest", []byte(`{"uuid": "*demo.Request"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 1 || !strings.Contains(string(responses[0]), `"revision":"13"`) {
+		t.Fatalf("unexpected responses %q", responses)
+	}
+	if calls != 1 {
+		t.Fatalf("intercepted %d calls, want 1", calls)
+	}
+}
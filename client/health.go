@@ -0,0 +1,144 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+)
+
+// CheckHealth asks an instance directly for the serving status of its service.
+func CheckHealth(instance *bonjour.Service, credentials []byte) (model.HealthCheckResponse_ServingStatus, error) {
+	return defaultClient.CheckHealth(instance, credentials)
+}
+
+func (e *Client) CheckHealth(instance *bonjour.Service, credentials []byte) (model.HealthCheckResponse_ServingStatus, error) {
+	proxy, err := e.dial(instance, credentials)
+	if err != nil {
+		return model.HealthCheckResponse_UNKNOWN, err
+	}
+	defer proxy.Close()
+
+	response := &model.HealthCheckResponse{}
+	if err := proxy.Invoke(&model.HealthCheckRequest{Service: instance.UUID}, response); err != nil {
+		return model.HealthCheckResponse_UNKNOWN, err
+	}
+	return response.Status, nil
+}
+
+func healthy(instance *bonjour.Service) bool {
+	return instance.Metadata[bonjour.MetadataHealth] != model.HealthCheckResponse_NOT_SERVING.String()
+}
+
+type probeKey struct {
+	uuid     string
+	provider bonjour.Provider
+}
+
+type probeState struct {
+	instance bonjour.Service
+	healthy  bool
+	used     time.Time
+}
+
+// Prober actively checks the instances Invoke is about to use, on top of the
+// status they announce. Instances are assumed healthy until first probed and
+// forgotten after going unused for a while.
+type Prober struct {
+	client      *Client
+	interval    time.Duration
+	credentials []byte
+	states      map[probeKey]*probeState
+	stop        chan struct{}
+	done        chan struct{}
+	mutex       *sync.Mutex
+}
+
+// NewProber probes through the default client.
+func NewProber(interval time.Duration, credentials []byte) *Prober {
+	return defaultClient.NewProber(interval, credentials)
+}
+
+func (e *Client) NewProber(interval time.Duration, credentials []byte) *Prober {
+	p := &Prober{
+		client:      e,
+		interval:    interval,
+		credentials: credentials,
+		states:      make(map[probeKey]*probeState),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		mutex:       &sync.Mutex{},
+	}
+	go p.loop()
+	return p
+}
+
+func (e *Prober) Close() {
+	close(e.stop)
+	<-e.done
+}
+
+func (e *Prober) Healthy(instance *bonjour.Service) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	key := probeKey{uuid: instance.UUID, provider: instance.Provider}
+	state, ok := e.states[key]
+	if !ok {
+		state = &probeState{
+			instance: *instance,
+			healthy:  true,
+		}
+		e.states[key] = state
+		go e.probe(key, state.instance)
+	}
+	state.used = time.Now()
+	return state.healthy
+}
+
+func (e *Prober) probe(key probeKey, instance bonjour.Service) {
+	status, err := e.client.CheckHealth(&instance, e.credentials)
+	if err != nil && loggingLevel&LogEnabled != LogDisabled {
+		logger.Println(instance.Provider.Address(), err)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if state, ok := e.states[key]; ok {
+		state.healthy = err == nil && status == model.HealthCheckResponse_SERVING
+	}
+}
+
+func (e *Prober) loop() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.stop:
+			return
+		}
+
+		e.mutex.Lock()
+		var wg sync.WaitGroup
+		for key, state := range e.states {
+			if time.Since(state.used) > 10*e.interval {
+				delete(e.states, key)
+				continue
+			}
+
+			wg.Add(1)
+			go func(key probeKey, instance bonjour.Service) {
+				defer wg.Done()
+				e.probe(key, instance)
+			}(key, state.instance)
+		}
+		e.mutex.Unlock()
+		wg.Wait()
+	}
+}
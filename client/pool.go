@@ -0,0 +1,280 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+const (
+	defaultMaxIdle     = 2
+	defaultIdleTimeout = 90 * time.Second
+)
+
+// PoolConfig bounds the connections persistent invocations keep to each
+// provider and set of credentials. Zero MaxOpen and IdleTimeout impose no
+// limit.
+type PoolConfig struct {
+	MaxIdle     int
+	MaxOpen     int
+	IdleTimeout time.Duration
+}
+
+type PoolStats struct {
+	Provider bonjour.Provider
+	Open     int
+	Idle     int
+	Dials    uint64
+	Reuses   uint64
+	// Evictions counts idle connections closed for outliving IdleTimeout,
+	// Broken those found unusable.
+	Evictions uint64
+	Broken    uint64
+}
+
+type poolKey struct {
+	provider    bonjour.Provider
+	credentials string
+}
+
+type idleProxy struct {
+	proxy *ClientProxy
+	since time.Time
+}
+
+type pool struct {
+	client *Client
+	key    poolKey
+	idle   []idleProxy
+	stats  PoolStats
+	timer  *time.Timer
+	closed bool
+	mutex  *sync.Mutex
+}
+
+// SetPoolConfig configures the pools of the default client.
+func SetPoolConfig(cfg PoolConfig) {
+	defaultClient.SetPoolConfig(cfg)
+}
+
+// Stats reports on the pools of the default client.
+func Stats() []PoolStats {
+	return defaultClient.Stats()
+}
+
+// ClosePersistentConns closes the pooled connections of the default client.
+func ClosePersistentConns() []error {
+	return defaultClient.Close()
+}
+
+func (e *Client) Stats() []PoolStats {
+	e.poolsMutex.Lock()
+	defer e.poolsMutex.Unlock()
+
+	stats := make([]PoolStats, 0, len(e.pools))
+	for _, p := range e.pools {
+		p.mutex.Lock()
+		s := p.stats
+		s.Idle = len(p.idle)
+		p.mutex.Unlock()
+
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Close closes the pooled connections, idle ones right away and those in use
+// once returned. The client remains usable.
+func (e *Client) Close() (errs []error) {
+	e.poolsMutex.Lock()
+	defer e.poolsMutex.Unlock()
+
+	for key, p := range e.pools {
+		errs = append(errs, p.close()...)
+		delete(e.pools, key)
+	}
+
+	return errs
+}
+
+func (e *Client) poolFor(provider bonjour.Provider, credentials []byte) *pool {
+	key := poolKey{
+		provider:    provider,
+		credentials: string(credentials),
+	}
+
+	e.poolsMutex.Lock()
+	defer e.poolsMutex.Unlock()
+
+	p, ok := e.pools[key]
+	if !ok {
+		p = &pool{
+			client: e,
+			key:    key,
+			stats: PoolStats{
+				Provider: provider,
+			},
+			mutex: &sync.Mutex{},
+		}
+		e.pools[key] = p
+	}
+	return p
+}
+
+// do runs call on a pooled connection. Connections found broken while the
+// request was being sent, by a broken pipe or a reset, are replaced and the
+// call retried once. Those failing after it was sent are not, as the
+// provider may have handled it.
+func (e *pool) do(instance *bonjour.Service, call func(*ClientProxy) error) error {
+	for retried := false; ; retried = true {
+		proxy, err := e.get(instance)
+		if err != nil {
+			return err
+		}
+
+		err = call(proxy)
+		unsent := err != nil && proxy.requestor.unsent()
+		_, remote := err.(*util.Error)
+		e.put(proxy, err != nil && !remote)
+
+		if !retried && unsent && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)) {
+			continue
+		}
+		return err
+	}
+}
+
+// get hands out the most recently used idle connection still alive, or dials
+// a new one unless MaxOpen are already.
+func (e *pool) get(instance *bonjour.Service) (*ClientProxy, error) {
+	cfg := e.client.poolConfig()
+
+	for {
+		e.mutex.Lock()
+		if len(e.idle) == 0 {
+			break
+		}
+		ip := e.idle[len(e.idle)-1]
+		e.idle = e.idle[:len(e.idle)-1]
+		e.mutex.Unlock()
+
+		if ip.proxy.requestor.alive() {
+			e.mutex.Lock()
+			e.stats.Reuses++
+			e.mutex.Unlock()
+			return ip.proxy, nil
+		}
+		e.discard(ip.proxy, &e.stats.Broken)
+	}
+
+	if cfg.MaxOpen > 0 && e.stats.Open >= cfg.MaxOpen {
+		e.mutex.Unlock()
+		return nil, util.ErrServiceUnavailable
+	}
+	e.stats.Open++
+	e.stats.Dials++
+	e.mutex.Unlock()
+
+	proxy, err := e.client.dial(instance, []byte(e.key.credentials))
+	if err != nil {
+		e.mutex.Lock()
+		e.stats.Open--
+		e.mutex.Unlock()
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// put returns proxy to the idle connections, or closes it if broken or not
+// wanted anymore.
+func (e *pool) put(proxy *ClientProxy, broken bool) {
+	cfg := e.client.poolConfig()
+
+	if broken {
+		e.discard(proxy, &e.stats.Broken)
+		return
+	}
+
+	e.mutex.Lock()
+	if e.closed || len(e.idle) >= cfg.MaxIdle {
+		e.mutex.Unlock()
+		e.discard(proxy, nil)
+		return
+	}
+	e.idle = append(e.idle, idleProxy{
+		proxy: proxy,
+		since: time.Now(),
+	})
+	if e.timer == nil && cfg.IdleTimeout > 0 {
+		e.timer = time.AfterFunc(cfg.IdleTimeout, e.evict)
+	}
+	e.mutex.Unlock()
+}
+
+// discard closes a connection taken out of the pool, counting it in counter
+// if any.
+func (e *pool) discard(proxy *ClientProxy, counter *uint64) {
+	e.mutex.Lock()
+	e.stats.Open--
+	if counter != nil {
+		*counter++
+	}
+	e.mutex.Unlock()
+
+	if err := proxy.Close(); err != nil {
+		e.client.log(err)
+	}
+}
+
+// evict closes the connections idle for longer than IdleTimeout and waits
+// for the oldest of the rest.
+func (e *pool) evict() {
+	cfg := e.client.poolConfig()
+
+	e.mutex.Lock()
+	e.timer = nil
+
+	var expired []*ClientProxy
+	idle := e.idle[:0]
+	for _, ip := range e.idle {
+		if cfg.IdleTimeout > 0 && time.Since(ip.since) >= cfg.IdleTimeout {
+			expired = append(expired, ip.proxy)
+			continue
+		}
+		idle = append(idle, ip)
+	}
+	e.idle = idle
+
+	if len(e.idle) > 0 && cfg.IdleTimeout > 0 {
+		e.timer = time.AfterFunc(cfg.IdleTimeout-time.Since(e.idle[0].since), e.evict)
+	}
+	e.mutex.Unlock()
+
+	for _, proxy := range expired {
+		e.discard(proxy, &e.stats.Evictions)
+	}
+}
+
+func (e *pool) close() (errs []error) {
+	e.mutex.Lock()
+	e.closed = true
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	idle := e.idle
+	e.idle = nil
+	e.stats.Open -= len(idle)
+	e.mutex.Unlock()
+
+	for _, ip := range idle {
+		if err := ip.proxy.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
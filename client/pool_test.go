@@ -0,0 +1,140 @@
+package client
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/resolver"
+	"github.com/t0rr3sp3dr0/middleair/server"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// resettingListener keeps the connections it accepts, for reset to drop
+// them all at once.
+type resettingListener struct {
+	net.Listener
+	conns []*net.TCPConn
+	mutex *sync.Mutex
+}
+
+func (e *resettingListener) Accept() (net.Conn, error) {
+	conn, err := e.Listener.Accept()
+	if err == nil {
+		e.mutex.Lock()
+		e.conns = append(e.conns, conn.(*net.TCPConn))
+		e.mutex.Unlock()
+	}
+	return conn, err
+}
+
+func (e *resettingListener) reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, conn := range e.conns {
+		conn.SetLinger(0)
+		conn.Close()
+	}
+	e.conns = nil
+}
+
+type resettingServer struct {
+	ln    *resettingListener
+	calls int
+}
+
+func (e *resettingServer) Registry() []*server.Service {
+	return []*server.Service{
+		&server.Service{
+			Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+			Handle: func(message proto.Message) (proto.Message, error) {
+				e.calls++
+				e.ln.reset()
+				return &model.LookupResponse{}, nil
+			},
+		},
+	}
+}
+
+func (e *resettingServer) Tags() []string {
+	return []string{}
+}
+
+// TestPoolKeepsReceivedRequests has the provider reset the connection once
+// it handled the request, which must not be sent again.
+func TestPoolKeepsReceivedRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := &resettingServer{ln: &resettingListener{Listener: ln, mutex: &sync.Mutex{}}}
+	s := server.NewServerFromListener(sp, sp.ln, util.Options{Host: "127.0.0.1"})
+	go s.Serve()
+	defer s.Close()
+
+	c := NewClient(Config{
+		Resolver: resolver.NewStaticResolver(map[string][]bonjour.Service{
+			"*": {{Provider: bonjour.Provider{Host: "127.0.0.1", Port: s.Port()}}},
+		}),
+	})
+	defer c.Close()
+
+	if err := c.Invoke(&model.LookupRequest{}, &model.LookupResponse{}, &Options{Persistent: true}); err == nil {
+		t.Fatal("expected the reset to fail the invocation")
+	}
+	if sp.calls != 1 {
+		t.Fatalf("handled %d times, want 1", sp.calls)
+	}
+}
+
+func TestClientPool(t *testing.T) {
+	s := serve(t, &lookupServer{}, util.Options{Host: "127.0.0.1"})
+
+	provider := bonjour.Provider{Host: "127.0.0.1", Port: s.Port()}
+	c := NewClient(Config{
+		Resolver: resolver.NewStaticResolver(map[string][]bonjour.Service{
+			"*": {{Provider: provider}},
+		}),
+		Pool: PoolConfig{MaxIdle: 1, IdleTimeout: 500 * time.Millisecond},
+	})
+	defer c.Close()
+
+	lookup := func() {
+		if err := c.Invoke(&model.LookupRequest{Uuid: "*demo.Request"}, &model.LookupResponse{}, &Options{Persistent: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := func() PoolStats {
+		for _, s := range c.Stats() {
+			if s.Provider == provider {
+				return s
+			}
+		}
+		return PoolStats{}
+	}
+
+	lookup()
+	lookup()
+	if s := stats(); s.Dials != 1 || s.Reuses != 1 || s.Open != 1 || s.Idle != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	time.Sleep(time.Second)
+	if s := stats(); s.Evictions != 1 || s.Open != 0 || s.Idle != 0 {
+		t.Fatalf("idle connection not evicted %+v", s)
+	}
+
+	lookup()
+	if s := stats(); s.Dials != 2 || s.Open != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if len(Stats()) != 0 {
+		t.Fatalf("default client pooled connections %+v", Stats())
+	}
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+)
+
+// Reflect asks an instance for the services it offers and the descriptors of
+// their messages, limited to the service uuid unless it is empty.
+func Reflect(instance *bonjour.Service, uuid string, credentials []byte) (*model.ServerReflectionResponse, error) {
+	return defaultClient.Reflect(instance, uuid, credentials)
+}
+
+func (e *Client) Reflect(instance *bonjour.Service, uuid string, credentials []byte) (*model.ServerReflectionResponse, error) {
+	proxy, err := e.dial(instance, credentials)
+	if err != nil {
+		return nil, err
+	}
+	defer proxy.Close()
+
+	response := &model.ServerReflectionResponse{}
+	if err := proxy.Invoke(&model.ServerReflectionRequest{Uuid: uuid}, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
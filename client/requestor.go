@@ -1,7 +1,9 @@
 package client
 
 import (
-	"fmt"
+	"net"
+	"reflect"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	model "github.com/t0rr3sp3dr0/middleair/proto"
@@ -34,34 +36,89 @@ func (e *Requestor) Close() error {
 	return e.crh.Close()
 }
 
+func (e *Requestor) alive() bool {
+	return e.crh.alive()
+}
+
+func (e *Requestor) unsent() bool {
+	return e.crh.unsent
+}
+
+func (e *Requestor) SetDeadline(t time.Time) error {
+	return e.crh.SetDeadline(t)
+}
+
+func (e *Requestor) LocalAddr() net.Addr {
+	return e.crh.LocalAddr()
+}
+
 func (e *Requestor) Invoke(req proto.Message, res proto.Message) error {
-	data, err := util.SelfDescribingMessage(req)
+	data, err := e.mashaler.Marshal(req)
 	if err != nil {
 		return err
 	}
 
-	err = e.crh.Send(data)
+	_, data, err = e.InvokeRaw(reflect.TypeOf(req).String(), data)
 	if err != nil {
 		return err
 	}
 
+	return e.mashaler.Unmarshal(data, res)
+}
+
+func (e *Requestor) InvokeRaw(uuid string, req []byte) (string, []byte, error) {
+	data, err := e.mashaler.Marshal(&model.SelfDescribingMessage{
+		TypeName:    uuid,
+		MessageData: req,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = e.crh.Send(data)
+	if err != nil {
+		return "", nil, err
+	}
+
 	response, err := e.crh.Receive()
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
 	selfDescribingMessage := &model.SelfDescribingMessage{}
 	if err := e.mashaler.Unmarshal(response, selfDescribingMessage); err != nil {
-		return err
+		return "", nil, err
 	}
 
 	if selfDescribingMessage.Error != nil {
-		return fmt.Errorf("%d: %s", selfDescribingMessage.Error.Code, selfDescribingMessage.Error.Message)
+		return "", nil, &util.Error{
+			Code:    selfDescribingMessage.Error.Code,
+			Message: selfDescribingMessage.Error.Message,
+		}
+	}
+
+	return selfDescribingMessage.TypeName, selfDescribingMessage.MessageData, nil
+}
+
+// Notify sends req without waiting for a response, which only udp allows.
+// Whether it arrives, let alone succeeds, is not known.
+func (e *Requestor) Notify(req proto.Message) error {
+	data, err := e.mashaler.Marshal(req)
+	if err != nil {
+		return err
 	}
 
-	if err := e.mashaler.Unmarshal(selfDescribingMessage.MessageData, res); err != nil {
+	return e.NotifyRaw(reflect.TypeOf(req).String(), data)
+}
+
+func (e *Requestor) NotifyRaw(uuid string, req []byte) error {
+	data, err := e.mashaler.Marshal(&model.SelfDescribingMessage{
+		TypeName:    uuid,
+		MessageData: req,
+	})
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return e.crh.SendOneWay(data)
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/client"
+	"github.com/t0rr3sp3dr0/middleair/gateway"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "HTTP listen address")
+	protoset := flag.String("protoset", "", "FileDescriptorSet to take types from instead of reflection")
+	namespace := flag.String("namespace", "", "discovery namespace")
+	mdns := flag.Bool("mdns", false, "also browse via mDNS/DNS-SD")
+	wait := flag.Duration("wait", 2*time.Second, "how long to wait for services to be discovered")
+	credentials := flag.String("credentials", "", "credentials to present to providers")
+	flag.Parse()
+
+	cfg := bonjour.DefaultConfig()
+	cfg.Namespace = *namespace
+	if *mdns {
+		cfg.Protocols |= bonjour.ProtocolMDNS
+	}
+	if err := bonjour.Start(cfg); err != nil {
+		panic(err)
+	}
+	defer bonjour.Stop()
+
+	options := &client.Options{
+		Credentials:      []byte(*credentials),
+		DiscoveryTimeout: *wait,
+	}
+	var source client.DescriptorSource = client.NewReflectionSource(options)
+	if *protoset != "" {
+		var err error
+		if source, err = client.NewFileSource(*protoset); err != nil {
+			panic(err)
+		}
+	}
+
+	gateway.SetLoggingLevel(gateway.LogEnabled)
+	mux := http.NewServeMux()
+	mux.Handle(gateway.Prefix, gateway.NewGateway(source, options))
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/t0rr3sp3dr0/middleair/client"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func call(args []string) error {
+	fs := flag.NewFlagSet("call", flag.ExitOnError)
+	tags := fs.String("tags", "", "comma-separated tags providers must have")
+	strict := fs.Bool("strict", false, "require every tag rather than any")
+	broadcast := fs.Bool("broadcast", false, "invoke every matching provider")
+	protoset := fs.String("protoset", "", "FileDescriptorSet to take types from instead of reflection")
+	fs.Parse(args)
+	uuid := fs.Arg(0)
+	if uuid == "" {
+		return fmt.Errorf("Usage: call [flags] <uuid> [json]")
+	}
+
+	var err error
+	body := []byte(fs.Arg(1))
+	if fs.NArg() < 2 {
+		if body, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return err
+		}
+	}
+
+	options := &client.Options{
+		StrictMatch:      *strict,
+		Broadcast:        *broadcast,
+		Credentials:      []byte(*credentials),
+		DiscoveryTimeout: *wait,
+	}
+	if *tags != "" {
+		options.Tags = strings.Split(*tags, ",")
+	}
+
+	var source client.DescriptorSource = client.NewReflectionSource(options)
+	if *protoset != "" {
+		if source, err = client.NewFileSource(*protoset); err != nil {
+			return err
+		}
+	}
+
+	dc := client.NewDynamicClient(source, options)
+	req, err := dc.NewRequest(uuid)
+	if err != nil {
+		return err
+	}
+	if err := protojson.Unmarshal(body, req); err != nil {
+		return err
+	}
+
+	responses, err := dc.Invoke(uuid, req)
+	if err != nil {
+		return err
+	}
+	for _, res := range responses {
+		fmt.Println(protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Format(res))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/t0rr3sp3dr0/middleair/client"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func describe(args []string) error {
+	fs := flag.NewFlagSet("describe", flag.ExitOnError)
+	fs.Parse(args)
+	uuid := fs.Arg(0)
+	if uuid == "" {
+		return fmt.Errorf("Usage: describe <uuid>")
+	}
+
+	provider, err := instance(uuid)
+	if err != nil {
+		return err
+	}
+	response, err := client.Reflect(provider, uuid, []byte(*credentials))
+	if err != nil {
+		return err
+	}
+	service, err := reflected(response, uuid)
+	if err != nil {
+		return err
+	}
+	registry, err := files(response)
+	if err != nil {
+		return err
+	}
+
+	responseType := service.ResponseType
+	if responseType == "" {
+		responseType = "?"
+	}
+	fmt.Printf("service %s\n", service.Uuid)
+	fmt.Printf("    provider %s\n", addresses(provider))
+	fmt.Printf("    tags [%s]\n", strings.Join(service.Tags, ","))
+	fmt.Printf("    call (%s) returns (%s)\n\n", service.RequestType, responseType)
+
+	p := &printer{
+		w:       os.Stdout,
+		printed: make(map[protoreflect.FullName]bool),
+	}
+	for _, name := range []string{service.RequestType, service.ResponseType} {
+		if name == "" {
+			continue
+		}
+		md, err := message(registry, name)
+		if err != nil {
+			return err
+		}
+		p.message(md)
+	}
+	return nil
+}
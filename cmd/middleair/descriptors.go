@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func files(response *model.ServerReflectionResponse) (*protoregistry.Files, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, data := range response.FileDescriptors {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(data, fd); err != nil {
+			return nil, err
+		}
+		set.File = append(set.File, fd)
+	}
+	return protodesc.NewFiles(set)
+}
+
+func message(files *protoregistry.Files, name string) (protoreflect.MessageDescriptor, error) {
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s: Not a Message", name)
+	}
+	return md, nil
+}
+
+// printer writes messages and the types they reference in .proto syntax,
+// each one once.
+type printer struct {
+	w       io.Writer
+	printed map[protoreflect.FullName]bool
+}
+
+func (e *printer) message(md protoreflect.MessageDescriptor) {
+	if e.printed[md.FullName()] {
+		return
+	}
+	e.printed[md.FullName()] = true
+
+	var referenced []protoreflect.Descriptor
+	fmt.Fprintf(e.w, "message %s {\n", md.FullName())
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+
+		label := ""
+		if fd.IsList() {
+			label = "repeated "
+		}
+		fmt.Fprintf(e.w, "    %s%s %s = %d;\n", label, e.fieldType(fd, &referenced), fd.Name(), fd.Number())
+	}
+	fmt.Fprintln(e.w, "}")
+	fmt.Fprintln(e.w)
+
+	for _, d := range referenced {
+		switch d := d.(type) {
+		case protoreflect.MessageDescriptor:
+			e.message(d)
+		case protoreflect.EnumDescriptor:
+			e.enum(d)
+		}
+	}
+}
+
+func (e *printer) fieldType(fd protoreflect.FieldDescriptor, referenced *[]protoreflect.Descriptor) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", e.fieldType(fd.MapKey(), referenced), e.fieldType(fd.MapValue(), referenced))
+	}
+
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		*referenced = append(*referenced, fd.Message())
+		return string(fd.Message().FullName())
+	case protoreflect.EnumKind:
+		*referenced = append(*referenced, fd.Enum())
+		return string(fd.Enum().FullName())
+	default:
+		return fd.Kind().String()
+	}
+}
+
+func (e *printer) enum(ed protoreflect.EnumDescriptor) {
+	if e.printed[ed.FullName()] {
+		return
+	}
+	e.printed[ed.FullName()] = true
+
+	fmt.Fprintf(e.w, "enum %s {\n", ed.FullName())
+	values := ed.Values()
+	for i := 0; i < values.Len(); i++ {
+		fmt.Fprintf(e.w, "    %s = %d;\n", values.Get(i).Name(), values.Get(i).Number())
+	}
+	fmt.Fprintln(e.w, "}")
+	fmt.Fprintln(e.w)
+}
+
+func reflected(response *model.ServerReflectionResponse, uuid string) (*model.ReflectedService, error) {
+	for _, service := range response.Services {
+		if service.Uuid == uuid {
+			return service, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: Not Offered", uuid)
+}
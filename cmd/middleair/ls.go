@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+)
+
+func ls(args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	fs.Parse(args)
+
+	if err := bonjour.Query(""); err != nil {
+		return err
+	}
+	time.Sleep(*wait)
+
+	var services []bonjour.Service
+	for _, instances := range bonjour.RemoteServices() {
+		services = append(services, instances...)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].UUID != services[j].UUID {
+			return services[i].UUID < services[j].UUID
+		}
+		return services[i].Provider.Address() < services[j].Provider.Address()
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "UUID\tADDRESS\tTAGS\tMETADATA")
+	for _, service := range services {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", service.UUID, addresses(&service), strings.Join(service.Tags, ","), metadata(&service))
+	}
+	return w.Flush()
+}
+
+func addresses(service *bonjour.Service) string {
+	var addresses []string
+	for _, provider := range service.Providers() {
+		addresses = append(addresses, provider.Address())
+	}
+	return strings.Join(addresses, ",")
+}
+
+func metadata(service *bonjour.Service) string {
+	pairs := make([]string, 0, len(service.Metadata))
+	for k, v := range service.Metadata {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+)
+
+var (
+	namespace   = flag.String("namespace", "", "discovery namespace")
+	mdns        = flag.Bool("mdns", false, "also browse via mDNS/DNS-SD")
+	wait        = flag.Duration("wait", 2*time.Second, "how long to wait for services to be discovered")
+	credentials = flag.String("credentials", "", "credentials to present to providers")
+
+	commands = map[string]func([]string) error{
+		"ls":       ls,
+		"describe": describe,
+		"call":     call,
+		"watch":    watch,
+	}
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] <command> [arguments]
+
+Commands:
+  ls                       list discovered services
+  describe <uuid>          show the messages of a service
+  call [flags] <uuid> [json]
+                           invoke a service with a JSON request, read from
+                           stdin when omitted, and print the responses
+  watch [uuid]             stream discovery events
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	command, ok := commands[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := bonjour.DefaultConfig()
+	cfg.Namespace = *namespace
+	if *mdns {
+		cfg.Protocols |= bonjour.ProtocolMDNS
+	}
+	if err := bonjour.Start(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer bonjour.Stop()
+
+	if err := command(flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		bonjour.Stop()
+		os.Exit(1)
+	}
+}
+
+// instance finds a healthy provider of uuid, waiting for discovery if needed.
+func instance(uuid string) (*bonjour.Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *wait)
+	defer cancel()
+
+	services, err := bonjour.WaitForService(ctx, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", uuid, err)
+	}
+	for i := range services {
+		if services[i].Metadata[bonjour.MetadataHealth] != model.HealthCheckResponse_NOT_SERVING.String() {
+			return &services[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s: No Healthy Instance", uuid)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+)
+
+func watch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	fs.Parse(args)
+	uuid := fs.Arg(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	var filter bonjour.Filter
+	if uuid != "" {
+		filter = func(service *bonjour.Service) bool {
+			return service.UUID == uuid
+		}
+	}
+	events := bonjour.Watch(ctx, filter)
+	if err := bonjour.Query(uuid); err != nil {
+		return err
+	}
+
+	for event := range events {
+		fmt.Printf("%-8s %s %s [%s] %s\n", strings.ToUpper(event.Type.String()), event.Service.UUID, addresses(&event.Service), strings.Join(event.Service.Tags, ","), metadata(&event.Service))
+	}
+	return nil
+}
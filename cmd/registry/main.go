@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/t0rr3sp3dr0/middleair/registry"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+func main() {
+	port := flag.Uint("port", registry.DefaultPort, "listen port")
+	credentials := flag.String("credentials", "", "credentials providers and clients must present")
+	flag.Parse()
+
+	registry.SetLoggingLevel(registry.LogEnabled)
+	if err := registry.NewRegistry().Serve(util.Options{
+		Port:        uint16(*port),
+		Protocol:    "tcp",
+		Credentials: []byte(*credentials),
+	}); err != nil {
+		panic(err)
+	}
+}
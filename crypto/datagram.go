@@ -0,0 +1,188 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+const (
+	keyIDSize     = 8
+	timestampSize = 8
+	nonceSize     = 12
+	tagSize       = 16
+
+	// DatagramOverhead is what sealing adds to the size of a datagram.
+	DatagramOverhead = keyIDSize + timestampSize + nonceSize + tagSize
+
+	// Datagrams sealed further from now than this are refused, which bounds
+	// how long their nonces are remembered for.
+	datagramWindow = 30 * time.Second
+)
+
+// Direction is the way datagrams go, authenticated along with them so that
+// those sent one way are not taken back the other.
+type Direction byte
+
+const (
+	ToServer Direction = iota + 1
+	ToClient
+)
+
+// DatagramCipher seals datagrams one by one with AES-GCM, under a key derived
+// from the shared key of a handshake or from a pre-shared one. Sealed
+// datagrams carry an ID of the key, so that receivers holding several can
+// tell which opens them.
+type DatagramCipher struct {
+	id        []byte
+	aead      cipher.AEAD
+	direction Direction
+}
+
+// NewDatagramCipher seals datagrams going direction and opens those coming
+// the other way.
+func NewDatagramCipher(key []byte, direction Direction) (*DatagramCipher, error) {
+	derived := sha256.Sum256(append([]byte("middleair datagram key"), key...))
+	id := sha256.Sum256(derived[:])
+
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DatagramCipher{
+		id:        id[:keyIDSize],
+		aead:      aead,
+		direction: direction,
+	}, nil
+}
+
+// DatagramCipher derives the cipher of this end of the connection, the other
+// using the opposite direction.
+func (e *SecureConn) DatagramCipher(direction Direction) (*DatagramCipher, error) {
+	return NewDatagramCipher(e.sharedKey, direction)
+}
+
+func (e *DatagramCipher) Seal(data []byte) ([]byte, error) {
+	return e.sealAt(data, time.Now())
+}
+
+func (e *DatagramCipher) sealAt(data []byte, now time.Time) ([]byte, error) {
+	header := make([]byte, keyIDSize+timestampSize+nonceSize, DatagramOverhead+len(data))
+	copy(header, e.id)
+	binary.LittleEndian.PutUint64(header[keyIDSize:], uint64(now.UnixNano()))
+	nonce := header[keyIDSize+timestampSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return e.aead.Seal(header, nonce, data, aad(header, e.direction)), nil
+}
+
+func (e *DatagramCipher) open(datagram []byte) ([]byte, time.Time, error) {
+	if len(datagram) < DatagramOverhead {
+		return nil, time.Time{}, util.ErrUnauthorized
+	}
+
+	incoming := ToClient
+	if e.direction == ToClient {
+		incoming = ToServer
+	}
+	nonce := datagram[keyIDSize+timestampSize : keyIDSize+timestampSize+nonceSize]
+	data, err := e.aead.Open(nil, nonce, datagram[keyIDSize+timestampSize+nonceSize:], aad(datagram, incoming))
+	if err != nil {
+		return nil, time.Time{}, util.ErrUnauthorized
+	}
+	return data, time.Unix(0, int64(binary.LittleEndian.Uint64(datagram[keyIDSize:]))), nil
+}
+
+// aad authenticates the key ID and timestamp of datagram with its direction.
+func aad(datagram []byte, direction Direction) []byte {
+	return append(append([]byte{}, datagram[:keyIDSize+timestampSize]...), byte(direction))
+}
+
+// Keyring opens datagrams sealed by any of the ciphers added to it, refusing
+// stale ones and those seen before.
+type Keyring struct {
+	ciphers map[string]*DatagramCipher
+	seen    map[string]time.Time
+	pruned  time.Time
+	mutex   *sync.Mutex
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		ciphers: make(map[string]*DatagramCipher),
+		seen:    make(map[string]time.Time),
+		mutex:   &sync.Mutex{},
+	}
+}
+
+func (e *Keyring) Add(c *DatagramCipher) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.ciphers[string(c.id)] = c
+}
+
+func (e *Keyring) Remove(c *DatagramCipher) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.ciphers[string(c.id)] == c {
+		delete(e.ciphers, string(c.id))
+	}
+}
+
+// Open returns the contents of datagram and the cipher to answer it with.
+func (e *Keyring) Open(datagram []byte) ([]byte, *DatagramCipher, error) {
+	if len(datagram) < DatagramOverhead {
+		return nil, nil, util.ErrUnauthorized
+	}
+
+	e.mutex.Lock()
+	c, ok := e.ciphers[string(datagram[:keyIDSize])]
+	e.mutex.Unlock()
+	if !ok {
+		return nil, nil, util.ErrUnauthorized
+	}
+
+	data, timestamp, err := c.open(datagram)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if timestamp.Before(now.Add(-datagramWindow)) || timestamp.After(now.Add(datagramWindow)) {
+		return nil, nil, util.ErrUnauthorized
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if now.Sub(e.pruned) > time.Second {
+		for nonce, expiry := range e.seen {
+			if now.After(expiry) {
+				delete(e.seen, nonce)
+			}
+		}
+		e.pruned = now
+	}
+	nonce := string(datagram[:keyIDSize+timestampSize+nonceSize])
+	if _, ok := e.seen[nonce]; ok {
+		return nil, nil, util.ErrUnauthorized
+	}
+	e.seen[nonce] = timestamp.Add(datagramWindow)
+
+	return data, c, nil
+}
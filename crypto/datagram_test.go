@@ -0,0 +1,97 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// ciphers returns the cipher and keyring of a client, then of a server,
+// sharing key.
+func ciphers(t *testing.T, key []byte) (*DatagramCipher, *Keyring, *DatagramCipher, *Keyring) {
+	client, err := NewDatagramCipher(key, ToServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewDatagramCipher(key, ToClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKeys, serverKeys := NewKeyring(), NewKeyring()
+	clientKeys.Add(client)
+	serverKeys.Add(server)
+	return client, clientKeys, server, serverKeys
+}
+
+func TestDatagramReflected(t *testing.T) {
+	client, clientKeys, server, serverKeys := ciphers(t, []byte("key"))
+
+	request, err := client.Seal([]byte("request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := clientKeys.Open(request); err != util.ErrUnauthorized {
+		t.Fatalf("reflected request opened: %v", err)
+	}
+	data, c, err := serverKeys.Open(request)
+	if err != nil || !bytes.Equal(data, []byte("request")) || c != server {
+		t.Fatalf("opened %q with %p, %v", data, c, err)
+	}
+
+	response, err := c.Seal([]byte("response"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := serverKeys.Open(response); err != util.ErrUnauthorized {
+		t.Fatalf("reflected response opened: %v", err)
+	}
+	if data, _, err := clientKeys.Open(response); err != nil || !bytes.Equal(data, []byte("response")) {
+		t.Fatalf("opened %q, %v", data, err)
+	}
+}
+
+func TestDatagramReplayed(t *testing.T) {
+	client, _, _, serverKeys := ciphers(t, []byte("key"))
+
+	datagram, err := client.Seal([]byte("request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := serverKeys.Open(datagram); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := serverKeys.Open(datagram); err != util.ErrUnauthorized {
+		t.Fatalf("replayed datagram opened: %v", err)
+	}
+
+	// Tampering with the nonce to dodge the replay check breaks the tag.
+	datagram[keyIDSize+timestampSize] ^= 1
+	if _, _, err := serverKeys.Open(datagram); err != util.ErrUnauthorized {
+		t.Fatalf("tampered datagram opened: %v", err)
+	}
+}
+
+func TestDatagramWindow(t *testing.T) {
+	client, _, _, serverKeys := ciphers(t, []byte("key"))
+
+	for _, offset := range []time.Duration{-datagramWindow - time.Second, datagramWindow + time.Second} {
+		datagram, err := client.sealAt([]byte("request"), time.Now().Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := serverKeys.Open(datagram); err != util.ErrUnauthorized {
+			t.Fatalf("datagram sealed %v from now opened: %v", offset, err)
+		}
+	}
+
+	datagram, err := client.sealAt([]byte("request"), time.Now().Add(-datagramWindow/2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := serverKeys.Open(datagram); err != nil {
+		t.Fatal(err)
+	}
+}
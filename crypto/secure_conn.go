@@ -8,7 +8,6 @@ import (
 	"crypto/rsa"
 	"encoding/binary"
 	"io"
-	"math"
 	"net"
 	"sync"
 	"time"
@@ -28,7 +27,34 @@ type SecureConn struct {
 	sharedKey       []byte
 }
 
+// Reject answers the handshake of conn with status in place of a key, which
+// spares generating one, and closes it.
+func Reject(conn net.Conn, status byte) error {
+	defer conn.Close()
+
+	w := &util.WrapperConn{
+		Conn: conn,
+	}
+	if _, err := w.ReadData(); err != nil {
+		return err
+	}
+	_, err := w.WriteData([]byte{status})
+	return err
+}
+
+const (
+	sharedKeySize = 512
+	// frameOverhead bounds what compression and encryption add to a
+	// payload, beyond the 1/256 of it stored gzip blocks and partial
+	// packet lengths may take.
+	frameOverhead = 1 << 10
+)
+
 func NewSecureConn(conn net.Conn) (*SecureConn, error) {
+	return newSecureConn(conn, sharedKeySize)
+}
+
+func newSecureConn(conn net.Conn, keySize int) (*SecureConn, error) {
 	e := &SecureConn{
 		Conn: conn,
 	}
@@ -42,8 +68,11 @@ func NewSecureConn(conn net.Conn) (*SecureConn, error) {
 		return nil, err
 	}
 
+	// Both packets must share the creation time or their key IDs differ.
+	creationTime := time.Now()
+
 	publicKeyBuf := bytes.NewBuffer(nil)
-	publicKey := packet.NewRSAPublicKey(time.Now(), &key.PublicKey)
+	publicKey := packet.NewRSAPublicKey(creationTime, &key.PublicKey)
 	if err := publicKey.Serialize(publicKeyBuf); err != nil {
 		return nil, err
 	}
@@ -56,13 +85,23 @@ func NewSecureConn(conn net.Conn) (*SecureConn, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Servers over capacity answer with a status rather than their key.
+	if len(pk) == 1 {
+		switch pk[0] {
+		case 503 % 256:
+			return nil, util.ErrServiceUnavailable
+
+		default:
+			return nil, util.ErrUnknown
+		}
+	}
 	pkt, err := packet.NewReader(bytes.NewBuffer(pk)).Next()
 	if err != nil {
 		return nil, err
 	}
 	e.publicKey = pkt.(*packet.PublicKey)
 
-	e.privateKey = packet.NewRSAPrivateKey(time.Now(), key)
+	e.privateKey = packet.NewRSAPrivateKey(creationTime, key)
 
 	e.publicEntities = []*openpgp.Entity{
 		newEntity(e.publicKey, nil),
@@ -72,7 +111,7 @@ func NewSecureConn(conn net.Conn) (*SecureConn, error) {
 		newEntity(publicKey, e.privateKey),
 	}
 
-	e.sharedKey = make([]byte, 512)
+	e.sharedKey = make([]byte, keySize)
 	if _, err := rand.Read(e.sharedKey); err != nil {
 		return nil, err
 	}
@@ -138,7 +177,7 @@ func NewSecureConn(conn net.Conn) (*SecureConn, error) {
 		return nil, err
 	}
 	defer preDecompressed.Close()
-	md, err := openpgp.ReadMessage(preDecompressed, e.privateEntities, nil, nil)
+	md, err := openpgp.ReadMessage(io.LimitReader(preDecompressed, util.MaxFrameSize+1), e.privateEntities, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -148,9 +187,12 @@ func NewSecureConn(conn net.Conn) (*SecureConn, error) {
 	}
 	defer postDecompressed.Close()
 	skBuf := bytes.NewBuffer(nil)
-	if _, err := io.Copy(skBuf, postDecompressed); err != nil {
+	if _, err := io.Copy(skBuf, io.LimitReader(postDecompressed, int64(len(e.sharedKey))+1)); err != nil {
 		return nil, err
 	}
+	if skBuf.Len() != len(e.sharedKey) {
+		return nil, util.ErrUnauthorized
+	}
 
 	for i, b := range skBuf.Bytes() {
 		e.sharedKey[i] = e.sharedKey[i] ^ b
@@ -215,19 +257,24 @@ func newEntity(pubKey *packet.PublicKey, privKey *packet.PrivateKey) *openpgp.En
 }
 
 func (e *SecureConn) ReadData() ([]byte, error) {
-	buf := make([]byte, math.MaxInt16)
-	n, err := e.Read(buf)
-	if n < 8 || err != nil {
+	return e.ReadDataLimit(util.MaxFrameSize)
+}
+
+// ReadDataLimit reads a payload of at most max bytes, failing with 413 on
+// larger ones before reading more of them than their frame holds.
+func (e *SecureConn) ReadDataLimit(max int) ([]byte, error) {
+	frameSize := max + max/256 + frameOverhead
+	buf, err := util.ReadFrameLimit(e.Conn, frameSize)
+	if err != nil {
 		return nil, err
 	}
-	size := int(binary.LittleEndian.Uint64(buf[:8]))
 
-	preDecompressed, err := gzip.NewReader(bytes.NewBuffer(buf[8 : size+8]))
+	preDecompressed, err := gzip.NewReader(bytes.NewBuffer(buf))
 	if err != nil {
 		return nil, err
 	}
 	defer preDecompressed.Close()
-	md, err := openpgp.ReadMessage(preDecompressed, nil, func([]openpgp.Key, bool) ([]byte, error) { return e.sharedKey, nil }, nil)
+	md, err := openpgp.ReadMessage(io.LimitReader(preDecompressed, int64(frameSize)+1), nil, func([]openpgp.Key, bool) ([]byte, error) { return e.sharedKey, nil }, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -236,12 +283,21 @@ func (e *SecureConn) ReadData() ([]byte, error) {
 		return nil, err
 	}
 	defer postDecompressed.Close()
-	decBuf := bytes.NewBuffer(nil)
-	if _, err := io.Copy(decBuf, postDecompressed); err != nil {
+
+	return readAll(postDecompressed, int64(max))
+}
+
+// readAll reads r to its end, failing with 413 past max bytes, which bounds
+// what a small compressed frame may expand to.
+func readAll(r io.Reader, max int64) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if _, err := io.Copy(buf, io.LimitReader(r, max+1)); err != nil {
 		return nil, err
 	}
-
-	return decBuf.Bytes(), nil
+	if int64(buf.Len()) > max {
+		return nil, util.ErrPayloadTooLarge
+	}
+	return buf.Bytes(), nil
 }
 
 func (e *SecureConn) WriteData(data []byte) (int, error) {
@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// TestSecureConnOversizedKey has the client send a shared key twice as long
+// as the one of the server, which must refuse it rather than panic.
+func TestSecureConnOversizedKey(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+
+		_, err = newSecureConn(conn, 2*sharedKeySize)
+		done <- err
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := NewSecureConn(conn); err != util.ErrUnauthorized {
+		t.Fatalf("accepted an oversized key: %v", err)
+	}
+	if err := <-done; err == nil {
+		t.Fatal("client handshake succeeded")
+	}
+}
+
+// TestSecureConnDecompressionBomb sends a frame well under MaxFrameSize that
+// expands past it.
+func TestSecureConnDecompressionBomb(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+
+		sc, err := NewSecureConn(conn)
+		if err != nil {
+			done <- err
+			return
+		}
+		_, err = sc.WriteData(make([]byte, util.MaxFrameSize+1))
+		done <- err
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	sc, err := NewSecureConn(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.ReadData(); err != util.ErrPayloadTooLarge {
+		t.Fatalf("read an oversized payload: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -74,7 +74,7 @@ func main() {
 					scanner.Scan()
 					req.Stdin = []byte(scanner.Text())
 
-					log.Println(req, "\n")
+					log.Printf("%v\n\n", req)
 
 					fmt.Print(">: [#tags] ")
 					scanner.Scan()
@@ -119,13 +119,13 @@ func main() {
 						opt.Credentials = append(opt.Credentials, byte(b))
 					}
 
-					log.Println(opt, "\n")
+					log.Printf("%v\n\n", opt)
 
 					if err := client.Invoke(req, res, opt); err != nil {
 						log.Println(err)
 						continue mainScan
 					}
-					log.Println(res, "\n")
+					log.Printf("%v\n\n", res)
 
 					break mainScan
 
@@ -140,7 +140,7 @@ func main() {
 					scanner.Scan()
 					req.Message = scanner.Text()
 
-					log.Println(req, "\n")
+					log.Printf("%v\n\n", req)
 
 					fmt.Print(">: [#tags] ")
 					scanner.Scan()
@@ -185,13 +185,13 @@ func main() {
 						opt.Credentials = append(opt.Credentials, byte(b))
 					}
 
-					log.Println(opt, "\n")
+					log.Printf("%v\n\n", opt)
 
 					if err := client.Invoke(req, res, opt); err != nil {
 						log.Println(err)
 						continue mainScan
 					}
-					log.Println(res, "\n")
+					log.Printf("%v\n\n", res)
 
 					break mainScan
 
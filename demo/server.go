@@ -20,22 +20,25 @@ func (e *Server) Registry() []*server.Service {
 			Handle: func(message proto.Message) (proto.Message, error) {
 				return &Response{}, nil
 			},
+			Response: reflect.TypeOf((*Response)(nil)),
 		},
 		&server.Service{
 			Interface: reflect.TypeOf((*RemoteShellRequest)(nil)),
 			Handle:    e.remoteShell,
+			Response:  reflect.TypeOf((*RemoteShellResponse)(nil)),
 		},
 		&server.Service{
 			Interface: reflect.TypeOf((*TextToSpeechRequest)(nil)),
 			Handle:    e.textToSpeech,
+			Response:  reflect.TypeOf((*TextToSpeechResponse)(nil)),
 		},
 	}
 
 	return services
 }
 
-func (e *Server) Tags() (tags [12]string) {
-	return tags
+func (e *Server) Tags() []string {
+	return []string{}
 }
 
 func (e *Server) remoteShell(message proto.Message) (proto.Message, error) {
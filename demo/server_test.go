@@ -22,6 +22,6 @@ func (e *BenchmarkServer) Registry() []*server.Service {
 	return services
 }
 
-func (e *BenchmarkServer) Tags() (tags [12]string) {
-	return tags
+func (e *BenchmarkServer) Tags() []string {
+	return []string{}
 }
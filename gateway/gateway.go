@@ -0,0 +1,179 @@
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/t0rr3sp3dr0/middleair/client"
+	"github.com/t0rr3sp3dr0/middleair/util"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	Prefix = "/v1/"
+
+	HeaderTags      = "X-MiddleAir-Tags"
+	HeaderStrict    = "X-MiddleAir-Strict"
+	HeaderBroadcast = "X-MiddleAir-Broadcast"
+
+	defaultMaxBodySize = 4 << 20
+)
+
+// Gateway serves POST /v1/{type} with the JSON form of the request of the
+// service type, answering with the JSON form of its response, or an array
+// of them when broadcasting. Tags are selected with the tags, strict and
+// broadcast query parameters or the equivalent headers.
+type Gateway struct {
+	source      client.DescriptorSource
+	options     *client.Options
+	MaxBodySize int64
+	// Client invokes the services, the default one if nil.
+	Client *client.Client
+}
+
+func NewGateway(source client.DescriptorSource, options *client.Options) *Gateway {
+	if options == nil {
+		options = &client.Options{}
+	}
+
+	return &Gateway{
+		source:      source,
+		options:     options,
+		MaxBodySize: defaultMaxBodySize,
+	}
+}
+
+func (e *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, Prefix) || len(r.URL.Path) == len(Prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, util.ErrMethodNotAllowed.Error(), http.StatusMethodNotAllowed)
+		return
+	}
+	uuid := strings.TrimPrefix(r.URL.Path, Prefix)
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, e.MaxBodySize))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			http.Error(w, util.ErrPayloadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	options, err := e.requestOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dc *client.DynamicClient
+	if e.Client != nil {
+		dc = e.Client.NewDynamicClient(e.source, options)
+	} else {
+		dc = client.NewDynamicClient(e.source, options)
+	}
+	req, err := dc.NewRequest(uuid)
+	if err != nil {
+		e.fail(w, uuid, err)
+		return
+	}
+	// An empty body stands for the empty request.
+	if len(body) > 0 {
+		if err := protojson.Unmarshal(body, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	responses, err := dc.Invoke(uuid, req)
+	if err != nil {
+		e.fail(w, uuid, err)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if options.Broadcast {
+		buf.WriteByte('[')
+	}
+	for i, res := range responses {
+		data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(res)
+		if err != nil {
+			e.fail(w, uuid, err)
+			return
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(data)
+	}
+	if options.Broadcast {
+		buf.WriteByte(']')
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
+
+func (e *Gateway) requestOptions(r *http.Request) (*client.Options, error) {
+	options := *e.options
+	query := r.URL.Query()
+
+	tags := append(query["tags"], r.Header.Values(HeaderTags)...)
+	if len(tags) > 0 {
+		options.Tags = []string{}
+		for _, value := range tags {
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					options.Tags = append(options.Tags, tag)
+				}
+			}
+		}
+	}
+
+	var err error
+	if options.StrictMatch, err = flag(query.Get("strict"), r.Header.Get(HeaderStrict), options.StrictMatch); err != nil {
+		return nil, err
+	}
+	if options.Broadcast, err = flag(query.Get("broadcast"), r.Header.Get(HeaderBroadcast), options.Broadcast); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+func (e *Gateway) fail(w http.ResponseWriter, uuid string, err error) {
+	if loggingLevel&LogEnabled != LogDisabled {
+		logger.Println(uuid, err)
+	}
+	http.Error(w, err.Error(), Status(err))
+}
+
+// Status maps the error of an invocation to the HTTP status to answer with.
+// Errors without a code happened on the way to providers.
+func Status(err error) int {
+	code := util.Code(err)
+	if code < 400 || code > 599 {
+		return http.StatusBadGateway
+	}
+	return int(code)
+}
+
+func flag(query string, header string, fallback bool) (bool, error) {
+	value := query
+	if value == "" {
+		value = header
+	}
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseBool(value)
+}
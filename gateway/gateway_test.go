@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/client"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/resolver"
+	"github.com/t0rr3sp3dr0/middleair/server"
+	"github.com/t0rr3sp3dr0/middleair/util"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type lookupServer struct{}
+
+func (e *lookupServer) Registry() []*server.Service {
+	return []*server.Service{
+		&server.Service{
+			Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+			Handle: func(message proto.Message) (proto.Message, error) {
+				req := message.(*model.LookupRequest)
+				if req.Uuid == "" {
+					return nil, util.ErrNotFound
+				}
+				return &model.LookupResponse{
+					Instances: []*model.RegistryInstance{{Uuid: req.Uuid}},
+					Revision:  7,
+				}, nil
+			},
+			Response: reflect.TypeOf((*model.LookupResponse)(nil)),
+		},
+	}
+}
+
+func (e *lookupServer) Tags() []string {
+	return []string{"fast"}
+}
+
+// protoset writes the descriptors of the registry messages where
+// NewFileSource can read them.
+func protoset(t *testing.T, dir string) string {
+	fd, err := protoregistry.GlobalFiles.FindFileByPath("registry.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := protov2.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(fd)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "registry.protoset")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGateway(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := server.NewServer(&lookupServer{}, util.Options{Host: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Serve()
+	port := s.Port()
+
+	source, err := client.NewFileSource(protoset(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := NewGateway(source, &client.Options{
+		Persistent: true,
+		Resolver: resolver.NewStaticResolver(map[string][]bonjour.Service{
+			"*": {{Provider: bonjour.Provider{Host: "127.0.0.1", Port: port}, Tags: []string{"fast"}}},
+		}),
+	})
+	defer client.ClosePersistentConns()
+
+	ts := httptest.NewServer(gateway)
+	defer ts.Close()
+
+	for _, test := range []struct {
+		method string
+		path   string
+		header http.Header
+		body   string
+		status int
+		want   string
+	}{
+		{"POST", "/v1/*proto.LookupRequest", nil, `{"uuid": "*demo.Request"}`, 200, `"revision":"7"`},
+		{"POST", "/v1/*proto.LookupRequest?tags=fast&broadcast=true", nil, `{"uuid": "*demo.Request"}`, 200, `[{"instances"`},
+		{"POST", "/v1/*proto.LookupRequest", http.Header{HeaderTags: {"slow"}}, `{"uuid": "*demo.Request"}`, 503, ""},
+		{"POST", "/v1/*proto.LookupRequest", nil, `{}`, 404, ""},
+		{"POST", "/v1/*proto.LookupRequest", nil, `{"bogus": 1}`, 400, ""},
+		{"POST", "/v1/*proto.Unknown", nil, `{}`, 404, ""},
+		{"GET", "/v1/*proto.LookupRequest", nil, "", 405, ""},
+	} {
+		req, err := http.NewRequest(test.method, ts.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != test.status || !strings.Contains(string(body), test.want) {
+			t.Fatalf("%s %s: %d %s, want %d containing %s", test.method, test.path, res.StatusCode, body, test.status, test.want)
+		}
+	}
+
+	// Only bodies over the limit are too large; other read failures are bad
+	// requests.
+	gateway.MaxBodySize = 4
+	for _, test := range []struct {
+		body   io.Reader
+		status int
+	}{
+		{strings.NewReader(`{"uuid": "*demo.Request"}`), 413},
+		{iotest.ErrReader(io.ErrUnexpectedEOF), 400},
+	} {
+		w := httptest.NewRecorder()
+		gateway.ServeHTTP(w, httptest.NewRequest("POST", "/v1/*proto.LookupRequest", test.body))
+		if w.Code != test.status {
+			t.Fatalf("expected %d, got %d %s", test.status, w.Code, w.Body)
+		}
+	}
+}
@@ -1,7 +1,19 @@
 module github.com/t0rr3sp3dr0/middleair
 
+go 1.22
+
+require (
+	github.com/golang/protobuf v1.5.4
+	github.com/miekg/dns v1.0.15
+	golang.org/x/crypto v0.30.0
+	golang.org/x/net v0.32.0
+	google.golang.org/grpc v1.70.0
+	google.golang.org/protobuf v1.36.5
+	gopkg.in/yaml.v2 v2.2.1
+)
+
 require (
-	github.com/golang/protobuf v1.2.0
-	golang.org/x/crypto v0.0.0-20181112202954-3d3f9f413869
-	golang.org/x/sync v0.0.0-20181108010431-42b317875d0f // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241202173237-19429a94021a // indirect
 )
@@ -0,0 +1,138 @@
+package grpcbridge
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/t0rr3sp3dr0/middleair/client"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/server"
+	"github.com/t0rr3sp3dr0/middleair/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type lookupServer struct{}
+
+func (e *lookupServer) Registry() []*server.Service {
+	return []*server.Service{
+		&server.Service{
+			Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+			Handle: func(message proto.Message) (proto.Message, error) {
+				req := message.(*model.LookupRequest)
+				if req.Uuid == "" {
+					return nil, util.ErrNotFound
+				}
+				return &model.LookupResponse{Revision: uint64(len(req.Uuid))}, nil
+			},
+			Response: reflect.TypeOf((*model.LookupResponse)(nil)),
+		},
+	}
+}
+
+func (e *lookupServer) Tags() []string {
+	return []string{}
+}
+
+func TestBridge(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer()
+	sd, err := Register(gs, "middleair.test.Registry", &lookupServer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go gs.Serve(ln)
+	defer gs.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///"+ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// MiddleAir handlers served over gRPC.
+	res := &model.LookupResponse{}
+	if err := conn.Invoke(context.Background(), "/middleair.test.Registry/Lookup", &model.LookupRequest{Uuid: "*demo.Request"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Revision != 13 {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	err = conn.Invoke(context.Background(), "/middleair.test.Registry/Lookup", &model.LookupRequest{}, res)
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected %v, got %v", codes.NotFound, err)
+	}
+
+	// The same gRPC service invoked through MiddleAir.
+	proxy, err := NewProxy(conn, sd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := server.NewServer(proxy, util.Options{Host: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Serve()
+
+	cp, err := client.NewClientProxy(util.Options{Host: "127.0.0.1", Port: s.Port(), Protocol: "tcp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	res = &model.LookupResponse{}
+	if err := cp.Invoke(&model.LookupRequest{Uuid: "*x"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Revision != 2 {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	if err := cp.Invoke(&model.LookupRequest{}, res); util.Code(err) != 404 {
+		t.Fatalf("expected %v, got %v", util.ErrNotFound, err)
+	}
+}
+
+// TestBridgeCanceled cancels a call whose handler never returns, which the
+// bridge must give up on.
+func TestBridgeCanceled(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	service := &server.Service{
+		Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+		Handle: func(message proto.Message) (proto.Message, error) {
+			<-block
+			return &model.LookupResponse{}, nil
+		},
+		Response: reflect.TypeOf((*model.LookupResponse)(nil)),
+	}
+	h := handler(&lookupServer{}, service, "/middleair.test.Registry/Lookup")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := h(nil, ctx, func(interface{}) error { return nil }, nil)
+		done <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Canceled {
+			t.Fatalf("expected %v, got %v", codes.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("call outlived its context")
+	}
+}
@@ -0,0 +1,22 @@
+package grpcbridge
+
+import (
+	"log"
+	"os"
+)
+
+type LoggingLevel int
+
+const (
+	LogDisabled LoggingLevel = 00
+	LogEnabled  LoggingLevel = ^0
+)
+
+var (
+	logger       = log.New(os.Stderr, "[grpcbridge] ", log.LstdFlags)
+	loggingLevel = LogDisabled
+)
+
+func SetLoggingLevel(ll LoggingLevel) {
+	loggingLevel = ll
+}
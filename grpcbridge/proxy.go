@@ -0,0 +1,91 @@
+package grpcbridge
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/t0rr3sp3dr0/middleair/server"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Proxy is a server.ServerProxy forwarding to the unary methods of a gRPC
+// service, so that an invoker announces them like its own. Request and
+// response types must be linked in, as MiddleAir routes by Go type.
+type Proxy struct {
+	services []*server.Service
+	tags     []string
+
+	// Timeout bounds every call to the gRPC service when positive.
+	Timeout time.Duration
+}
+
+func NewProxy(conn grpc.ClientConnInterface, sd protoreflect.ServiceDescriptor, tags []string) (*Proxy, error) {
+	e := &Proxy{
+		tags: append([]string{}, tags...),
+	}
+
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Printf("%s: skipping streaming method", md.FullName())
+			}
+			continue
+		}
+
+		input, err := goType(md.Input())
+		if err != nil {
+			return nil, err
+		}
+		output, err := goType(md.Output())
+		if err != nil {
+			return nil, err
+		}
+
+		e.services = append(e.services, &server.Service{
+			Interface: input,
+			Handle:    e.handle(conn, fmt.Sprintf("/%s/%s", sd.FullName(), md.Name()), output),
+			Response:  output,
+		})
+	}
+	return e, nil
+}
+
+func (e *Proxy) Registry() []*server.Service {
+	return e.services
+}
+
+func (e *Proxy) Tags() []string {
+	return e.tags
+}
+
+func (e *Proxy) handle(conn grpc.ClientConnInterface, fullMethod string, output reflect.Type) server.HandleFn {
+	return func(message proto.Message) (proto.Message, error) {
+		ctx := context.Background()
+		if e.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+			defer cancel()
+		}
+
+		res := newMessage(output)
+		if err := conn.Invoke(ctx, fullMethod, message, res); err != nil {
+			return nil, fromStatus(err)
+		}
+		return res, nil
+	}
+}
+
+func goType(md protoreflect.MessageDescriptor) (reflect.Type, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", md.FullName(), err)
+	}
+	return reflect.TypeOf(proto.MessageV1(mt.New().Interface())), nil
+}
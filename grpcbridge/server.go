@@ -0,0 +1,167 @@
+package grpcbridge
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/server"
+	"github.com/t0rr3sp3dr0/middleair/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Describe declares the registry of sp as the gRPC service serviceName, with
+// a unary method per service named after its request, less the Request
+// suffix. Every service must set its Response.
+func Describe(serviceName string, sp server.ServerProxy) (protoreflect.ServiceDescriptor, error) {
+	return describe(serviceName, sp.Registry())
+}
+
+// describe declares registry, whose order the methods keep.
+func describe(serviceName string, registry []*server.Service) (protoreflect.ServiceDescriptor, error) {
+	name := protoreflect.FullName(serviceName)
+	if !name.IsValid() {
+		return nil, fmt.Errorf("%s: Invalid Service Name", serviceName)
+	}
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:   protov2.String(strings.Replace(serviceName, ".", "/", -1) + ".proto"),
+		Syntax: protov2.String("proto3"),
+	}
+	if pkg := name.Parent(); pkg != "" {
+		file.Package = protov2.String(string(pkg))
+	}
+	sdp := &descriptorpb.ServiceDescriptorProto{
+		Name: protov2.String(string(name.Name())),
+	}
+	file.Service = append(file.Service, sdp)
+
+	dependencies := make(map[string]bool)
+	methods := make(map[string]bool)
+	for _, service := range registry {
+		if service.Response == nil {
+			return nil, fmt.Errorf("%v: Response Type Unknown", service.Interface)
+		}
+		input := descriptorOf(service.Interface)
+		output := descriptorOf(service.Response)
+
+		method := methodName(input)
+		if methods[method] {
+			return nil, fmt.Errorf("%s: Duplicate Method", method)
+		}
+		methods[method] = true
+
+		for _, md := range []protoreflect.MessageDescriptor{input, output} {
+			if path := md.ParentFile().Path(); !dependencies[path] {
+				dependencies[path] = true
+				file.Dependency = append(file.Dependency, path)
+			}
+		}
+		sdp.Method = append(sdp.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       protov2.String(method),
+			InputType:  protov2.String("." + string(input.FullName())),
+			OutputType: protov2.String("." + string(output.FullName())),
+		})
+	}
+
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, err
+	}
+	return fd.Services().Get(0), nil
+}
+
+// Register serves the registry of sp on gs as described by Describe, whose
+// result it returns for publishing through gRPC reflection. Handlers run
+// within the limits of sp, as they would behind an invoker.
+func Register(gs grpc.ServiceRegistrar, serviceName string, sp server.ServerProxy) (protoreflect.ServiceDescriptor, error) {
+	// Methods pair with services by index, so both come from one registry.
+	registry := sp.Registry()
+	sd, err := describe(serviceName, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := &grpc.ServiceDesc{
+		ServiceName: serviceName,
+		HandlerType: (*server.ServerProxy)(nil),
+		Metadata:    sd.ParentFile().Path(),
+	}
+	for i, service := range registry {
+		method := string(sd.Methods().Get(i).Name())
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: method,
+			Handler:    handler(sp, service, fmt.Sprintf("/%s/%s", serviceName, method)),
+		})
+	}
+
+	gs.RegisterService(desc, sp)
+	return sd, nil
+}
+
+func handler(sp server.ServerProxy, service *server.Service, fullMethod string) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	handle := func(ctx context.Context, req interface{}) (interface{}, error) {
+		res, err := server.CallContext(ctx, sp, service, req.(proto.Message))
+		if err != nil && ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if err != nil {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(fullMethod, err)
+			}
+			code := util.Code(err)
+			if code == 0 {
+				code = 500
+			}
+			return nil, toStatus(code, err.Error())
+		}
+
+		if er, ok := res.(*model.ErrorResponse); ok {
+			return nil, toStatus(er.GetError().GetCode(), er.GetError().GetMessage())
+		}
+		return res, nil
+	}
+
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := newMessage(service.Interface)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+
+		if interceptor == nil {
+			return handle(ctx, req)
+		}
+		return interceptor(ctx, req, &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: fullMethod,
+		}, handle)
+	}
+}
+
+func newMessage(t reflect.Type) proto.Message {
+	if t.Kind() == reflect.Ptr {
+		return reflect.New(t.Elem()).Interface().(proto.Message)
+	}
+	return reflect.Zero(t).Interface().(proto.Message)
+}
+
+func descriptorOf(t reflect.Type) protoreflect.MessageDescriptor {
+	return proto.MessageReflect(newMessage(t)).Descriptor()
+}
+
+func methodName(md protoreflect.MessageDescriptor) string {
+	name := string(md.Name())
+	if method := strings.TrimSuffix(name, "Request"); method != "" {
+		return method
+	}
+	return name
+}
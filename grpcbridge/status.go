@@ -0,0 +1,51 @@
+package grpcbridge
+
+import (
+	"github.com/t0rr3sp3dr0/middleair/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	codesByStatus = map[uint64]codes.Code{
+		400: codes.InvalidArgument,
+		401: codes.Unauthenticated,
+		403: codes.PermissionDenied,
+		404: codes.NotFound,
+		405: codes.Unimplemented,
+		413: codes.ResourceExhausted,
+		417: codes.FailedPrecondition,
+		500: codes.Internal,
+		503: codes.Unavailable,
+		504: codes.DeadlineExceeded,
+	}
+
+	errorsByCode = map[codes.Code]error{
+		codes.Unauthenticated:    util.ErrUnauthorized,
+		codes.PermissionDenied:   util.ErrForbidden,
+		codes.NotFound:           util.ErrNotFound,
+		codes.Unimplemented:      util.ErrMethodNotAllowed,
+		codes.ResourceExhausted:  util.ErrServiceUnavailable,
+		codes.FailedPrecondition: util.ErrExpectationFailed,
+		codes.Unavailable:        util.ErrServiceUnavailable,
+		codes.DeadlineExceeded:   util.ErrGatewayTimeout,
+	}
+)
+
+// toStatus turns an error of a MiddleAir handler into a gRPC status.
+func toStatus(code uint64, message string) error {
+	c, ok := codesByStatus[code]
+	if !ok {
+		c = codes.Unknown
+	}
+	return status.Error(c, message)
+}
+
+// fromStatus turns the status of a gRPC call into the util error closest to
+// it, if any.
+func fromStatus(err error) error {
+	if ue, ok := errorsByCode[status.Code(err)]; ok {
+		return ue
+	}
+	return err
+}
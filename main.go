@@ -1,10 +1,50 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
 	"github.com/t0rr3sp3dr0/middleair/bonjour"
 )
 
 func main() {
+	cfg := bonjour.DefaultConfig()
+	flag.StringVar(&cfg.Namespace, "namespace", cfg.Namespace, "discovery namespace")
+	flag.DurationVar(&cfg.AnnounceInterval, "interval", cfg.AnnounceInterval, "announce interval")
+	flag.DurationVar(&cfg.Expiry, "expiry", cfg.Expiry, "instance expiry")
+	flag.IntVar(&cfg.TTL, "ttl", cfg.TTL, "multicast TTL")
+	mdns := flag.Bool("mdns", false, "also advertise and browse via mDNS/DNS-SD")
+	advertise := flag.String("advertise", "", "comma-separated addresses to advertise instead of the interface ones")
+	flag.Parse()
+
+	for _, host := range strings.Split(*advertise, ",") {
+		if host == "" {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			panic(fmt.Errorf("Invalid Address: %v", host))
+		}
+		cfg.Addresses = append(cfg.Addresses, ip)
+	}
+
+	if *mdns {
+		cfg.Protocols |= bonjour.ProtocolMDNS
+	}
+
 	bonjour.SetLoggingLevel(bonjour.LogEveryone)
-	<-make(chan struct{})
+	if err := bonjour.Start(cfg); err != nil {
+		panic(err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	bonjour.Stop()
 }
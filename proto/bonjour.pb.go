@@ -19,19 +19,26 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type ServiceAnnouncement struct {
-	Uuid                 string   `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
-	Port                 int32    `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
-	Tags                 []string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Uuid                 string            `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Port                 int32             `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	Tags                 []string          `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	Version              uint32            `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	Metadata             map[string]string `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Namespace            string            `protobuf:"bytes,6,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	TtlMs                uint32            `protobuf:"varint,7,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	Query                string            `protobuf:"bytes,8,opt,name=query,proto3" json:"query,omitempty"`
+	UnicastResponse      bool              `protobuf:"varint,9,opt,name=unicast_response,json=unicastResponse,proto3" json:"unicast_response,omitempty"`
+	Addresses            []string          `protobuf:"bytes,10,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
 }
 
 func (m *ServiceAnnouncement) Reset()         { *m = ServiceAnnouncement{} }
 func (m *ServiceAnnouncement) String() string { return proto.CompactTextString(m) }
 func (*ServiceAnnouncement) ProtoMessage()    {}
 func (*ServiceAnnouncement) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bonjour_00d00f83c6cefa17, []int{0}
+	return fileDescriptor_bonjour_2046d86d6c9b244c, []int{0}
 }
 func (m *ServiceAnnouncement) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ServiceAnnouncement.Unmarshal(m, b)
@@ -72,20 +79,80 @@ func (m *ServiceAnnouncement) GetTags() []string {
 	return nil
 }
 
+func (m *ServiceAnnouncement) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *ServiceAnnouncement) GetMetadata() map[string]string {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *ServiceAnnouncement) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *ServiceAnnouncement) GetTtlMs() uint32 {
+	if m != nil {
+		return m.TtlMs
+	}
+	return 0
+}
+
+func (m *ServiceAnnouncement) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *ServiceAnnouncement) GetUnicastResponse() bool {
+	if m != nil {
+		return m.UnicastResponse
+	}
+	return false
+}
+
+func (m *ServiceAnnouncement) GetAddresses() []string {
+	if m != nil {
+		return m.Addresses
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*ServiceAnnouncement)(nil), "proto.ServiceAnnouncement")
+	proto.RegisterMapType((map[string]string)(nil), "proto.ServiceAnnouncement.MetadataEntry")
 }
 
-func init() { proto.RegisterFile("bonjour.proto", fileDescriptor_bonjour_00d00f83c6cefa17) }
+func init() { proto.RegisterFile("bonjour.proto", fileDescriptor_bonjour_2046d86d6c9b244c) }
 
-var fileDescriptor_bonjour_00d00f83c6cefa17 = []byte{
-	// 116 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4d, 0xca, 0xcf, 0xcb,
-	0xca, 0x2f, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x05, 0x53, 0x4a, 0x81, 0x5c,
-	0xc2, 0xc1, 0xa9, 0x45, 0x65, 0x99, 0xc9, 0xa9, 0x8e, 0x79, 0x79, 0xf9, 0xa5, 0x79, 0xc9, 0xa9,
-	0xb9, 0xa9, 0x79, 0x25, 0x42, 0x42, 0x5c, 0x2c, 0xa5, 0xa5, 0x99, 0x29, 0x12, 0x8c, 0x0a, 0x8c,
-	0x1a, 0x9c, 0x41, 0x60, 0x36, 0x48, 0xac, 0x20, 0xbf, 0xa8, 0x44, 0x82, 0x49, 0x81, 0x51, 0x83,
-	0x35, 0x08, 0xcc, 0x06, 0x89, 0x95, 0x24, 0xa6, 0x17, 0x4b, 0x30, 0x2b, 0x30, 0x83, 0xd4, 0x81,
-	0xd8, 0x49, 0x6c, 0x60, 0x93, 0x8d, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0x80, 0xb8, 0x64, 0x45,
-	0x71, 0x00, 0x00, 0x00,
+var fileDescriptor_bonjour_2046d86d6c9b244c = []byte{
+	// 285 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x90, 0x41, 0x4b, 0x03, 0x31,
+	0x10, 0x85, 0x49, 0xb7, 0xdb, 0x76, 0x47, 0x8a, 0x25, 0x2a, 0x04, 0xf1, 0x10, 0x3c, 0xc5, 0x4b,
+	0x0f, 0x7a, 0x11, 0x3d, 0x09, 0x7a, 0xec, 0x25, 0xfe, 0x80, 0x92, 0xee, 0x0e, 0xb2, 0xda, 0x4d,
+	0xd6, 0x64, 0x52, 0xe8, 0xd5, 0x5f, 0x2e, 0x49, 0x57, 0x8b, 0xe0, 0x29, 0xef, 0x7d, 0xcc, 0x30,
+	0x2f, 0x0f, 0xe6, 0x1b, 0x67, 0xdf, 0x5d, 0xf4, 0xcb, 0xde, 0x3b, 0x72, 0xbc, 0xcc, 0xcf, 0xf5,
+	0x57, 0x01, 0x67, 0xaf, 0xe8, 0x77, 0x6d, 0x8d, 0x4f, 0xd6, 0xba, 0x68, 0x6b, 0xec, 0xd0, 0x12,
+	0xe7, 0x30, 0x8e, 0xb1, 0x6d, 0x04, 0x93, 0x4c, 0x55, 0x3a, 0xeb, 0xc4, 0x7a, 0xe7, 0x49, 0x8c,
+	0x24, 0x53, 0xa5, 0xce, 0x3a, 0x31, 0x32, 0x6f, 0x41, 0x14, 0xb2, 0x48, 0x73, 0x49, 0x73, 0x01,
+	0xd3, 0x1d, 0xfa, 0xd0, 0x3a, 0x2b, 0xc6, 0x92, 0xa9, 0xb9, 0xfe, 0xb1, 0xfc, 0x19, 0x66, 0x1d,
+	0x92, 0x69, 0x0c, 0x19, 0x51, 0xca, 0x42, 0x9d, 0xdc, 0xaa, 0x43, 0x9c, 0xe5, 0x3f, 0x19, 0x96,
+	0xab, 0x61, 0xf4, 0xc5, 0x92, 0xdf, 0xeb, 0xdf, 0x4d, 0x7e, 0x05, 0x95, 0x35, 0x1d, 0x86, 0xde,
+	0xd4, 0x28, 0x26, 0x39, 0xe0, 0x11, 0xf0, 0x0b, 0x98, 0x10, 0x6d, 0xd7, 0x5d, 0x10, 0xd3, 0x7c,
+	0xbc, 0x24, 0xda, 0xae, 0x02, 0x3f, 0x87, 0xf2, 0x33, 0xa2, 0xdf, 0x8b, 0x59, 0x5e, 0x38, 0x18,
+	0x7e, 0x03, 0x8b, 0x68, 0xdb, 0xda, 0x04, 0x5a, 0x7b, 0x0c, 0xbd, 0xb3, 0x01, 0x45, 0x25, 0x99,
+	0x9a, 0xe9, 0xd3, 0x81, 0xeb, 0x01, 0xa7, 0xab, 0xa6, 0x69, 0x3c, 0x86, 0x80, 0x41, 0x40, 0xfe,
+	0xee, 0x11, 0x5c, 0x3e, 0xc2, 0xfc, 0x4f, 0x5c, 0xbe, 0x80, 0xe2, 0x03, 0xf7, 0x43, 0x7f, 0x49,
+	0xa6, 0x04, 0x3b, 0xb3, 0x8d, 0x98, 0xfb, 0xab, 0xf4, 0xc1, 0x3c, 0x8c, 0xee, 0xd9, 0x66, 0x92,
+	0x3b, 0xb8, 0xfb, 0x1e, 0x00, 0x30, 0xc5, 0x8c, 0x44, 0xa3, 0x01, 0x00, 0x00,
 }
@@ -1,3 +1,3 @@
-//go:generate protoc -I . --go_out=. bonjour.proto util.proto
+//go:generate protoc -I . --go_out=. bonjour.proto health.proto reflection.proto registry.proto util.proto
 
 package proto
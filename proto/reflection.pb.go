@@ -0,0 +1,191 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: reflection.proto
+
+package proto
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type ServerReflectionRequest struct {
+	Uuid                 string   `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ServerReflectionRequest) Reset()         { *m = ServerReflectionRequest{} }
+func (m *ServerReflectionRequest) String() string { return proto.CompactTextString(m) }
+func (*ServerReflectionRequest) ProtoMessage()    {}
+func (*ServerReflectionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_reflection_3a548bdc5ed135a3, []int{0}
+}
+func (m *ServerReflectionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServerReflectionRequest.Unmarshal(m, b)
+}
+func (m *ServerReflectionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServerReflectionRequest.Marshal(b, m, deterministic)
+}
+func (dst *ServerReflectionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServerReflectionRequest.Merge(dst, src)
+}
+func (m *ServerReflectionRequest) XXX_Size() int {
+	return xxx_messageInfo_ServerReflectionRequest.Size(m)
+}
+func (m *ServerReflectionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServerReflectionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServerReflectionRequest proto.InternalMessageInfo
+
+func (m *ServerReflectionRequest) GetUuid() string {
+	if m != nil {
+		return m.Uuid
+	}
+	return ""
+}
+
+type ReflectedService struct {
+	Uuid                 string   `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	RequestType          string   `protobuf:"bytes,2,opt,name=request_type,json=requestType,proto3" json:"request_type,omitempty"`
+	ResponseType         string   `protobuf:"bytes,3,opt,name=response_type,json=responseType,proto3" json:"response_type,omitempty"`
+	Tags                 []string `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReflectedService) Reset()         { *m = ReflectedService{} }
+func (m *ReflectedService) String() string { return proto.CompactTextString(m) }
+func (*ReflectedService) ProtoMessage()    {}
+func (*ReflectedService) Descriptor() ([]byte, []int) {
+	return fileDescriptor_reflection_3a548bdc5ed135a3, []int{1}
+}
+func (m *ReflectedService) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReflectedService.Unmarshal(m, b)
+}
+func (m *ReflectedService) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReflectedService.Marshal(b, m, deterministic)
+}
+func (dst *ReflectedService) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReflectedService.Merge(dst, src)
+}
+func (m *ReflectedService) XXX_Size() int {
+	return xxx_messageInfo_ReflectedService.Size(m)
+}
+func (m *ReflectedService) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReflectedService.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReflectedService proto.InternalMessageInfo
+
+func (m *ReflectedService) GetUuid() string {
+	if m != nil {
+		return m.Uuid
+	}
+	return ""
+}
+
+func (m *ReflectedService) GetRequestType() string {
+	if m != nil {
+		return m.RequestType
+	}
+	return ""
+}
+
+func (m *ReflectedService) GetResponseType() string {
+	if m != nil {
+		return m.ResponseType
+	}
+	return ""
+}
+
+func (m *ReflectedService) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+type ServerReflectionResponse struct {
+	Services             []*ReflectedService `protobuf:"bytes,1,rep,name=services,proto3" json:"services,omitempty"`
+	FileDescriptors      [][]byte            `protobuf:"bytes,2,rep,name=file_descriptors,json=fileDescriptors,proto3" json:"file_descriptors,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *ServerReflectionResponse) Reset()         { *m = ServerReflectionResponse{} }
+func (m *ServerReflectionResponse) String() string { return proto.CompactTextString(m) }
+func (*ServerReflectionResponse) ProtoMessage()    {}
+func (*ServerReflectionResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_reflection_3a548bdc5ed135a3, []int{2}
+}
+func (m *ServerReflectionResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServerReflectionResponse.Unmarshal(m, b)
+}
+func (m *ServerReflectionResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServerReflectionResponse.Marshal(b, m, deterministic)
+}
+func (dst *ServerReflectionResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServerReflectionResponse.Merge(dst, src)
+}
+func (m *ServerReflectionResponse) XXX_Size() int {
+	return xxx_messageInfo_ServerReflectionResponse.Size(m)
+}
+func (m *ServerReflectionResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServerReflectionResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServerReflectionResponse proto.InternalMessageInfo
+
+func (m *ServerReflectionResponse) GetServices() []*ReflectedService {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
+func (m *ServerReflectionResponse) GetFileDescriptors() [][]byte {
+	if m != nil {
+		return m.FileDescriptors
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*ServerReflectionRequest)(nil), "proto.ServerReflectionRequest")
+	proto.RegisterType((*ReflectedService)(nil), "proto.ReflectedService")
+	proto.RegisterType((*ServerReflectionResponse)(nil), "proto.ServerReflectionResponse")
+}
+
+func init() { proto.RegisterFile("reflection.proto", fileDescriptor_reflection_3a548bdc5ed135a3) }
+
+var fileDescriptor_reflection_3a548bdc5ed135a3 = []byte{
+	// 219 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x8f, 0xb1, 0x4e, 0xc3, 0x30,
+	0x10, 0x86, 0x95, 0x3a, 0x20, 0xb8, 0x06, 0x11, 0xdd, 0x52, 0x8f, 0x26, 0x2c, 0x61, 0xa0, 0x03,
+	0x7d, 0x05, 0x9e, 0xc0, 0xb0, 0x57, 0x90, 0x5c, 0x91, 0xa5, 0xaa, 0x36, 0x77, 0x0e, 0x52, 0x19,
+	0x79, 0x72, 0x64, 0xbb, 0x14, 0x09, 0x3a, 0xf9, 0xf4, 0xf9, 0xbb, 0x5f, 0xff, 0x41, 0xcb, 0xb4,
+	0xd9, 0xd2, 0x10, 0x9d, 0xdf, 0x2d, 0x03, 0xfb, 0xe8, 0xf1, 0x2c, 0x3f, 0xdd, 0x3d, 0x2c, 0x9e,
+	0x88, 0x3f, 0x88, 0xed, 0x51, 0xb0, 0xf4, 0x3e, 0x91, 0x44, 0x44, 0xa8, 0xa7, 0xc9, 0x8d, 0xba,
+	0x32, 0x55, 0x7f, 0x69, 0xf3, 0xdc, 0x7d, 0x55, 0xd0, 0x1e, 0x4c, 0x1a, 0xd3, 0xa2, 0x1b, 0xe8,
+	0x94, 0x88, 0x37, 0xd0, 0x70, 0xc9, 0x59, 0xc7, 0x7d, 0x20, 0x3d, 0xcb, 0x7f, 0xf3, 0x03, 0x7b,
+	0xde, 0x07, 0xc2, 0x5b, 0xb8, 0x62, 0x92, 0xe0, 0x77, 0x42, 0xc5, 0x51, 0xd9, 0x69, 0x7e, 0x60,
+	0x96, 0x10, 0xea, 0xf8, 0xf2, 0x26, 0xba, 0x36, 0x2a, 0x65, 0xa7, 0xb9, 0xfb, 0x04, 0xfd, 0xbf,
+	0x73, 0xd9, 0xc1, 0x15, 0x5c, 0x48, 0xa9, 0x25, 0xba, 0x32, 0xaa, 0x9f, 0x3f, 0x2c, 0xca, 0xc1,
+	0xcb, 0xbf, 0xb5, 0xed, 0x51, 0xc4, 0x3b, 0x68, 0x37, 0x6e, 0x4b, 0xeb, 0x91, 0x64, 0x60, 0x17,
+	0xa2, 0x67, 0xd1, 0x33, 0xa3, 0xfa, 0xc6, 0x5e, 0x27, 0xfe, 0xf8, 0x8b, 0x5f, 0xcf, 0x73, 0xd8,
+	0xea, 0x7b, 0x00, 0x69, 0x3b, 0xb7, 0x2e, 0x51, 0x01, 0x00, 0x00,
+}
@@ -0,0 +1,572 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: registry.proto
+
+package proto
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type RegistryInstance struct {
+	Uuid                 string            `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Host                 string            `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Zone                 string            `protobuf:"bytes,3,opt,name=zone,proto3" json:"zone,omitempty"`
+	Port                 uint32            `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	Tags                 []string          `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
+	Metadata             map[string]string `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Network              string            `protobuf:"bytes,7,opt,name=network,proto3" json:"network,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *RegistryInstance) Reset()         { *m = RegistryInstance{} }
+func (m *RegistryInstance) String() string { return proto.CompactTextString(m) }
+func (*RegistryInstance) ProtoMessage()    {}
+func (*RegistryInstance) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{0}
+}
+func (m *RegistryInstance) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegistryInstance.Unmarshal(m, b)
+}
+func (m *RegistryInstance) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegistryInstance.Marshal(b, m, deterministic)
+}
+func (dst *RegistryInstance) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegistryInstance.Merge(dst, src)
+}
+func (m *RegistryInstance) XXX_Size() int {
+	return xxx_messageInfo_RegistryInstance.Size(m)
+}
+func (m *RegistryInstance) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegistryInstance.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegistryInstance proto.InternalMessageInfo
+
+func (m *RegistryInstance) GetUuid() string {
+	if m != nil {
+		return m.Uuid
+	}
+	return ""
+}
+
+func (m *RegistryInstance) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
+}
+
+func (m *RegistryInstance) GetZone() string {
+	if m != nil {
+		return m.Zone
+	}
+	return ""
+}
+
+func (m *RegistryInstance) GetPort() uint32 {
+	if m != nil {
+		return m.Port
+	}
+	return 0
+}
+
+func (m *RegistryInstance) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *RegistryInstance) GetMetadata() map[string]string {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *RegistryInstance) GetNetwork() string {
+	if m != nil {
+		return m.Network
+	}
+	return ""
+}
+
+type RegisterRequest struct {
+	Instances            []*RegistryInstance `protobuf:"bytes,1,rep,name=instances,proto3" json:"instances,omitempty"`
+	TtlMs                uint32              `protobuf:"varint,2,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *RegisterRequest) Reset()         { *m = RegisterRequest{} }
+func (m *RegisterRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterRequest) ProtoMessage()    {}
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{1}
+}
+func (m *RegisterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterRequest.Unmarshal(m, b)
+}
+func (m *RegisterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterRequest.Marshal(b, m, deterministic)
+}
+func (dst *RegisterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterRequest.Merge(dst, src)
+}
+func (m *RegisterRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterRequest.Size(m)
+}
+func (m *RegisterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterRequest proto.InternalMessageInfo
+
+func (m *RegisterRequest) GetInstances() []*RegistryInstance {
+	if m != nil {
+		return m.Instances
+	}
+	return nil
+}
+
+func (m *RegisterRequest) GetTtlMs() uint32 {
+	if m != nil {
+		return m.TtlMs
+	}
+	return 0
+}
+
+type RegisterResponse struct {
+	LeaseId              string   `protobuf:"bytes,1,opt,name=lease_id,json=leaseId,proto3" json:"lease_id,omitempty"`
+	TtlMs                uint32   `protobuf:"varint,2,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterResponse) Reset()         { *m = RegisterResponse{} }
+func (m *RegisterResponse) String() string { return proto.CompactTextString(m) }
+func (*RegisterResponse) ProtoMessage()    {}
+func (*RegisterResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{2}
+}
+func (m *RegisterResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterResponse.Unmarshal(m, b)
+}
+func (m *RegisterResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterResponse.Marshal(b, m, deterministic)
+}
+func (dst *RegisterResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterResponse.Merge(dst, src)
+}
+func (m *RegisterResponse) XXX_Size() int {
+	return xxx_messageInfo_RegisterResponse.Size(m)
+}
+func (m *RegisterResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterResponse proto.InternalMessageInfo
+
+func (m *RegisterResponse) GetLeaseId() string {
+	if m != nil {
+		return m.LeaseId
+	}
+	return ""
+}
+
+func (m *RegisterResponse) GetTtlMs() uint32 {
+	if m != nil {
+		return m.TtlMs
+	}
+	return 0
+}
+
+type HeartbeatRequest struct {
+	LeaseId              string   `protobuf:"bytes,1,opt,name=lease_id,json=leaseId,proto3" json:"lease_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatRequest) Reset()         { *m = HeartbeatRequest{} }
+func (m *HeartbeatRequest) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatRequest) ProtoMessage()    {}
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{3}
+}
+func (m *HeartbeatRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatRequest.Unmarshal(m, b)
+}
+func (m *HeartbeatRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatRequest.Marshal(b, m, deterministic)
+}
+func (dst *HeartbeatRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatRequest.Merge(dst, src)
+}
+func (m *HeartbeatRequest) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatRequest.Size(m)
+}
+func (m *HeartbeatRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatRequest proto.InternalMessageInfo
+
+func (m *HeartbeatRequest) GetLeaseId() string {
+	if m != nil {
+		return m.LeaseId
+	}
+	return ""
+}
+
+type HeartbeatResponse struct {
+	TtlMs                uint32   `protobuf:"varint,1,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatResponse) Reset()         { *m = HeartbeatResponse{} }
+func (m *HeartbeatResponse) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatResponse) ProtoMessage()    {}
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{4}
+}
+func (m *HeartbeatResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatResponse.Unmarshal(m, b)
+}
+func (m *HeartbeatResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatResponse.Marshal(b, m, deterministic)
+}
+func (dst *HeartbeatResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatResponse.Merge(dst, src)
+}
+func (m *HeartbeatResponse) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatResponse.Size(m)
+}
+func (m *HeartbeatResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatResponse proto.InternalMessageInfo
+
+func (m *HeartbeatResponse) GetTtlMs() uint32 {
+	if m != nil {
+		return m.TtlMs
+	}
+	return 0
+}
+
+type DeregisterRequest struct {
+	LeaseId              string   `protobuf:"bytes,1,opt,name=lease_id,json=leaseId,proto3" json:"lease_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeregisterRequest) Reset()         { *m = DeregisterRequest{} }
+func (m *DeregisterRequest) String() string { return proto.CompactTextString(m) }
+func (*DeregisterRequest) ProtoMessage()    {}
+func (*DeregisterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{5}
+}
+func (m *DeregisterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeregisterRequest.Unmarshal(m, b)
+}
+func (m *DeregisterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeregisterRequest.Marshal(b, m, deterministic)
+}
+func (dst *DeregisterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeregisterRequest.Merge(dst, src)
+}
+func (m *DeregisterRequest) XXX_Size() int {
+	return xxx_messageInfo_DeregisterRequest.Size(m)
+}
+func (m *DeregisterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeregisterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeregisterRequest proto.InternalMessageInfo
+
+func (m *DeregisterRequest) GetLeaseId() string {
+	if m != nil {
+		return m.LeaseId
+	}
+	return ""
+}
+
+type DeregisterResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeregisterResponse) Reset()         { *m = DeregisterResponse{} }
+func (m *DeregisterResponse) String() string { return proto.CompactTextString(m) }
+func (*DeregisterResponse) ProtoMessage()    {}
+func (*DeregisterResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{6}
+}
+func (m *DeregisterResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeregisterResponse.Unmarshal(m, b)
+}
+func (m *DeregisterResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeregisterResponse.Marshal(b, m, deterministic)
+}
+func (dst *DeregisterResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeregisterResponse.Merge(dst, src)
+}
+func (m *DeregisterResponse) XXX_Size() int {
+	return xxx_messageInfo_DeregisterResponse.Size(m)
+}
+func (m *DeregisterResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeregisterResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeregisterResponse proto.InternalMessageInfo
+
+type LookupRequest struct {
+	Uuid                 string   `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LookupRequest) Reset()         { *m = LookupRequest{} }
+func (m *LookupRequest) String() string { return proto.CompactTextString(m) }
+func (*LookupRequest) ProtoMessage()    {}
+func (*LookupRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{7}
+}
+func (m *LookupRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LookupRequest.Unmarshal(m, b)
+}
+func (m *LookupRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LookupRequest.Marshal(b, m, deterministic)
+}
+func (dst *LookupRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LookupRequest.Merge(dst, src)
+}
+func (m *LookupRequest) XXX_Size() int {
+	return xxx_messageInfo_LookupRequest.Size(m)
+}
+func (m *LookupRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LookupRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LookupRequest proto.InternalMessageInfo
+
+func (m *LookupRequest) GetUuid() string {
+	if m != nil {
+		return m.Uuid
+	}
+	return ""
+}
+
+type LookupResponse struct {
+	Instances            []*RegistryInstance `protobuf:"bytes,1,rep,name=instances,proto3" json:"instances,omitempty"`
+	Revision             uint64              `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *LookupResponse) Reset()         { *m = LookupResponse{} }
+func (m *LookupResponse) String() string { return proto.CompactTextString(m) }
+func (*LookupResponse) ProtoMessage()    {}
+func (*LookupResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{8}
+}
+func (m *LookupResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LookupResponse.Unmarshal(m, b)
+}
+func (m *LookupResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LookupResponse.Marshal(b, m, deterministic)
+}
+func (dst *LookupResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LookupResponse.Merge(dst, src)
+}
+func (m *LookupResponse) XXX_Size() int {
+	return xxx_messageInfo_LookupResponse.Size(m)
+}
+func (m *LookupResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LookupResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LookupResponse proto.InternalMessageInfo
+
+func (m *LookupResponse) GetInstances() []*RegistryInstance {
+	if m != nil {
+		return m.Instances
+	}
+	return nil
+}
+
+func (m *LookupResponse) GetRevision() uint64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	Uuid                 string   `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Revision             uint64   `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	TimeoutMs            uint32   `protobuf:"varint,3,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchRequest) Reset()         { *m = WatchRequest{} }
+func (m *WatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()    {}
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{9}
+}
+func (m *WatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRequest.Unmarshal(m, b)
+}
+func (m *WatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRequest.Marshal(b, m, deterministic)
+}
+func (dst *WatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRequest.Merge(dst, src)
+}
+func (m *WatchRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRequest.Size(m)
+}
+func (m *WatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRequest proto.InternalMessageInfo
+
+func (m *WatchRequest) GetUuid() string {
+	if m != nil {
+		return m.Uuid
+	}
+	return ""
+}
+
+func (m *WatchRequest) GetRevision() uint64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *WatchRequest) GetTimeoutMs() uint32 {
+	if m != nil {
+		return m.TimeoutMs
+	}
+	return 0
+}
+
+type WatchResponse struct {
+	Instances            []*RegistryInstance `protobuf:"bytes,1,rep,name=instances,proto3" json:"instances,omitempty"`
+	Revision             uint64              `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *WatchResponse) Reset()         { *m = WatchResponse{} }
+func (m *WatchResponse) String() string { return proto.CompactTextString(m) }
+func (*WatchResponse) ProtoMessage()    {}
+func (*WatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_registry_81b488bf840b2354, []int{10}
+}
+func (m *WatchResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchResponse.Unmarshal(m, b)
+}
+func (m *WatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchResponse.Marshal(b, m, deterministic)
+}
+func (dst *WatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchResponse.Merge(dst, src)
+}
+func (m *WatchResponse) XXX_Size() int {
+	return xxx_messageInfo_WatchResponse.Size(m)
+}
+func (m *WatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchResponse proto.InternalMessageInfo
+
+func (m *WatchResponse) GetInstances() []*RegistryInstance {
+	if m != nil {
+		return m.Instances
+	}
+	return nil
+}
+
+func (m *WatchResponse) GetRevision() uint64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*RegistryInstance)(nil), "proto.RegistryInstance")
+	proto.RegisterMapType((map[string]string)(nil), "proto.RegistryInstance.MetadataEntry")
+	proto.RegisterType((*RegisterRequest)(nil), "proto.RegisterRequest")
+	proto.RegisterType((*RegisterResponse)(nil), "proto.RegisterResponse")
+	proto.RegisterType((*HeartbeatRequest)(nil), "proto.HeartbeatRequest")
+	proto.RegisterType((*HeartbeatResponse)(nil), "proto.HeartbeatResponse")
+	proto.RegisterType((*DeregisterRequest)(nil), "proto.DeregisterRequest")
+	proto.RegisterType((*DeregisterResponse)(nil), "proto.DeregisterResponse")
+	proto.RegisterType((*LookupRequest)(nil), "proto.LookupRequest")
+	proto.RegisterType((*LookupResponse)(nil), "proto.LookupResponse")
+	proto.RegisterType((*WatchRequest)(nil), "proto.WatchRequest")
+	proto.RegisterType((*WatchResponse)(nil), "proto.WatchResponse")
+}
+
+func init() { proto.RegisterFile("registry.proto", fileDescriptor_registry_81b488bf840b2354) }
+
+var fileDescriptor_registry_81b488bf840b2354 = []byte{
+	// 409 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x52, 0x5d, 0x8b, 0xd3, 0x40,
+	0x14, 0x25, 0x4d, 0x3f, 0xaf, 0x66, 0xcd, 0x0e, 0x2b, 0x8e, 0x0b, 0x42, 0x18, 0x11, 0x8a, 0x60,
+	0x1e, 0x14, 0x41, 0xf4, 0x49, 0x58, 0xc1, 0x05, 0xfb, 0x32, 0x2f, 0x3e, 0x49, 0x99, 0xb6, 0x97,
+	0xdd, 0xd0, 0x34, 0x13, 0x67, 0x6e, 0x56, 0xea, 0xaf, 0xf0, 0x27, 0xcb, 0x4c, 0x26, 0x6d, 0x5d,
+	0x6c, 0x1f, 0x04, 0x9f, 0x7a, 0xee, 0xe9, 0xb9, 0x67, 0xce, 0x3d, 0x04, 0xce, 0x0c, 0xde, 0x14,
+	0x96, 0xcc, 0x36, 0xaf, 0x8d, 0x26, 0xcd, 0x06, 0xfe, 0x47, 0xfc, 0xea, 0x41, 0x2a, 0xc3, 0x3f,
+	0xd7, 0x95, 0x25, 0x55, 0x2d, 0x91, 0x31, 0xe8, 0x37, 0x4d, 0xb1, 0xe2, 0x51, 0x16, 0x4d, 0x27,
+	0xd2, 0x63, 0xc7, 0xdd, 0x6a, 0x4b, 0xbc, 0xd7, 0x72, 0x0e, 0x3b, 0xee, 0xa7, 0xae, 0x90, 0xc7,
+	0x2d, 0xe7, 0xb0, 0xe3, 0x6a, 0x6d, 0x88, 0xf7, 0xb3, 0x68, 0x9a, 0x48, 0x8f, 0x1d, 0x47, 0xea,
+	0xc6, 0xf2, 0x41, 0x16, 0x3b, 0x9d, 0xc3, 0xec, 0x23, 0x8c, 0x37, 0x48, 0x6a, 0xa5, 0x48, 0xf1,
+	0x61, 0x16, 0x4f, 0x1f, 0xbc, 0x7e, 0xd1, 0x26, 0xcb, 0xef, 0xc7, 0xc9, 0x67, 0x41, 0xf7, 0xa9,
+	0x22, 0xb3, 0x95, 0xbb, 0x35, 0xc6, 0x61, 0x54, 0x21, 0xfd, 0xd0, 0x66, 0xcd, 0x47, 0x3e, 0x41,
+	0x37, 0x5e, 0x7e, 0x80, 0xe4, 0x8f, 0x25, 0x96, 0x42, 0xbc, 0xc6, 0x6d, 0x38, 0xc8, 0x41, 0x76,
+	0x01, 0x83, 0x3b, 0x55, 0x36, 0x18, 0x0e, 0x6a, 0x87, 0xf7, 0xbd, 0x77, 0x91, 0x98, 0xc3, 0xa3,
+	0x36, 0x02, 0x1a, 0x89, 0xdf, 0x1b, 0xb4, 0xc4, 0xde, 0xc2, 0xa4, 0x08, 0x69, 0x2c, 0x8f, 0x7c,
+	0xda, 0x27, 0x47, 0xd2, 0xca, 0xbd, 0x92, 0x3d, 0x86, 0x21, 0x51, 0x39, 0xdf, 0x58, 0xff, 0x48,
+	0x22, 0x07, 0x44, 0xe5, 0xcc, 0x8a, 0x2b, 0x48, 0xf7, 0x0f, 0xd8, 0x5a, 0x57, 0x16, 0xd9, 0x53,
+	0x18, 0x97, 0xa8, 0x2c, 0xce, 0x77, 0xb5, 0x8f, 0xfc, 0x7c, 0xbd, 0x3a, 0xe6, 0xf2, 0x0a, 0xd2,
+	0xcf, 0xa8, 0x0c, 0x2d, 0x50, 0x51, 0x97, 0xf3, 0xb8, 0x8b, 0x78, 0x09, 0xe7, 0x07, 0xf2, 0xf0,
+	0xea, 0xde, 0x3a, 0x3a, 0xb4, 0xce, 0xe1, 0xfc, 0x0a, 0xcd, 0xbd, 0x0e, 0x4e, 0x78, 0x5f, 0x00,
+	0x3b, 0xd4, 0xb7, 0xe6, 0xe2, 0x39, 0x24, 0x5f, 0xb4, 0x5e, 0x37, 0x75, 0xe7, 0xf0, 0x97, 0xcf,
+	0x4a, 0x2c, 0xe1, 0xac, 0x13, 0x85, 0x4c, 0xff, 0xd8, 0xf5, 0x25, 0x8c, 0x0d, 0xde, 0x15, 0xb6,
+	0xd0, 0x95, 0xef, 0xa9, 0x2f, 0x77, 0xb3, 0xf8, 0x06, 0x0f, 0xbf, 0x2a, 0x5a, 0xde, 0x9e, 0x08,
+	0x72, 0x6a, 0x9f, 0x3d, 0x03, 0xa0, 0x62, 0x83, 0xba, 0x21, 0x57, 0x55, 0xec, 0xab, 0x9a, 0x04,
+	0x66, 0x66, 0xc5, 0x02, 0x92, 0x60, 0xff, 0xdf, 0x4e, 0x58, 0x0c, 0xfd, 0xfa, 0x9b, 0xdf, 0x03,
+	0x00, 0x00, 0xb7, 0x16, 0x97, 0xc7, 0x03, 0x00, 0x00,
+}
@@ -0,0 +1,307 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/client"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+type Client struct {
+	options util.Options
+	proxy   *client.ClientProxy
+	mutex   *sync.Mutex
+}
+
+func NewClient(options util.Options) *Client {
+	if options.Protocol == "" {
+		options.Protocol = "tcp"
+	}
+
+	return &Client{
+		options: options,
+		mutex:   &sync.Mutex{},
+	}
+}
+
+func (e *Client) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.proxy == nil {
+		return nil
+	}
+	err := e.proxy.Close()
+	e.proxy = nil
+	return err
+}
+
+// The connection is dropped on any failure and dialled again on the next call.
+// A non-zero deadline bounds dialing as well as fn.
+func (e *Client) invoke(deadline time.Time, fn func(*client.ClientProxy) error) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.proxy == nil {
+		options := e.options
+		if !deadline.IsZero() {
+			if d := time.Until(deadline); options.DialTimeout <= 0 || d < options.DialTimeout {
+				options.DialTimeout = d
+			}
+		}
+		proxy, err := client.NewClientProxy(options)
+		if err != nil {
+			return err
+		}
+		e.proxy = proxy
+	}
+
+	if !deadline.IsZero() {
+		proxy := e.proxy
+		if err := proxy.SetDeadline(deadline); err != nil {
+			return err
+		}
+		defer proxy.SetDeadline(time.Time{})
+	}
+
+	if err := fn(e.proxy); err != nil {
+		e.proxy.Close()
+		e.proxy = nil
+		return err
+	}
+	return nil
+}
+
+func (e *Client) call(req proto.Message, res proto.Message) error {
+	return e.callContext(context.Background(), req, res)
+}
+
+func (e *Client) callContext(ctx context.Context, req proto.Message, res proto.Message) error {
+	deadline, _ := ctx.Deadline()
+	return e.invoke(deadline, func(proxy *client.ClientProxy) error {
+		return proxy.Invoke(req, res)
+	})
+}
+
+// Register leases the services until the returned lease is closed, keeping
+// it alive with heartbeats. Services without a host are published under the
+// local address of the connection to the registry.
+func (e *Client) Register(services []bonjour.Service, ttl time.Duration) (*Lease, error) {
+	l := &Lease{
+		registry: e,
+		services: append([]bonjour.Service{}, services...),
+		ttl:      ttl,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		once:     &sync.Once{},
+		mutex:    &sync.Mutex{},
+	}
+	if err := l.register(); err != nil {
+		return nil, err
+	}
+
+	go l.loop()
+	return l, nil
+}
+
+func (e *Client) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	response := &model.LookupResponse{}
+	if err := e.callContext(ctx, &model.LookupRequest{Uuid: uuid}, response); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if len(response.Instances) == 0 {
+		return nil, util.ErrNotFound
+	}
+	return services(response.Instances), nil
+}
+
+// Watch calls fn with the current instances of uuid, or of every service when
+// uuid is empty, and again after every change until ctx is done. It holds a
+// connection of its own, as long polls would block other calls.
+func (e *Client) Watch(ctx context.Context, uuid string, fn func([]bonjour.Service)) error {
+	proxy, err := client.NewClientProxy(e.options)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		proxy.Close()
+	}()
+
+	var revision uint64
+	for {
+		timeout := defaultWatchTimeout
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+			timeout = time.Until(deadline)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		request := &model.WatchRequest{
+			Uuid:      uuid,
+			Revision:  revision,
+			TimeoutMs: uint32(timeout / time.Millisecond),
+		}
+		response := &model.WatchResponse{}
+		if err := proxy.Invoke(request, response); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		if response.Revision != revision {
+			revision = response.Revision
+			fn(services(response.Instances))
+		}
+	}
+}
+
+type Lease struct {
+	registry *Client
+	services []bonjour.Service
+	id       string
+	ttl      time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	once     *sync.Once
+	mutex    *sync.Mutex
+}
+
+func (e *Lease) ID() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.id
+}
+
+// Close deregisters the services. Later calls do nothing.
+func (e *Lease) Close() error {
+	var err error
+	e.once.Do(func() {
+		close(e.stop)
+		<-e.done
+
+		err = e.registry.call(&model.DeregisterRequest{LeaseId: e.ID()}, &model.DeregisterResponse{})
+	})
+	return err
+}
+
+func (e *Lease) register() error {
+	request := &model.RegisterRequest{
+		TtlMs: uint32(e.ttl / time.Millisecond),
+	}
+	response := &model.RegisterResponse{}
+	err := e.registry.invoke(time.Time{}, func(proxy *client.ClientProxy) error {
+		host, zone := localHost(proxy.LocalAddr())
+		for i := range e.services {
+			request.Instances = append(request.Instances, instance(&e.services[i], host, zone))
+		}
+		return proxy.Invoke(request, response)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.id = response.LeaseId
+	e.ttl = time.Duration(response.TtlMs) * time.Millisecond
+	return nil
+}
+
+func (e *Lease) loop() {
+	defer close(e.done)
+
+	for {
+		e.mutex.Lock()
+		interval := e.ttl / 3
+		e.mutex.Unlock()
+
+		select {
+		case <-time.After(interval):
+		case <-e.stop:
+			return
+		}
+
+		response := &model.HeartbeatResponse{}
+		if err := e.registry.call(&model.HeartbeatRequest{LeaseId: e.ID()}, response); err != nil {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(err)
+			}
+			continue
+		}
+
+		if response.TtlMs == 0 {
+			if err := e.register(); err != nil {
+				if loggingLevel&LogEnabled != LogDisabled {
+					logger.Println(err)
+				}
+			}
+		}
+	}
+}
+
+func localHost(addr net.Addr) (string, string) {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String(), tcpAddr.Zone
+	}
+
+	host, _, _ := net.SplitHostPort(addr.String())
+	return host, ""
+}
+
+func instance(service *bonjour.Service, host string, zone string) *model.RegistryInstance {
+	e := &model.RegistryInstance{
+		Uuid:     service.UUID,
+		Host:     service.Provider.Host,
+		Zone:     service.Provider.Zone,
+		Port:     uint32(service.Provider.Port),
+		Tags:     service.Tags,
+		Metadata: service.Metadata,
+		Network:  service.Provider.Network,
+	}
+	if e.Host == "" {
+		e.Host, e.Zone = host, zone
+	}
+	return e
+}
+
+func services(instances []*model.RegistryInstance) []bonjour.Service {
+	services := make([]bonjour.Service, 0, len(instances))
+	for _, instance := range instances {
+		services = append(services, bonjour.Service{
+			UUID: instance.Uuid,
+			Provider: bonjour.Provider{
+				Host:    instance.Host,
+				Zone:    instance.Zone,
+				Port:    uint16(instance.Port),
+				Network: instance.Network,
+			},
+			Tags:     instance.Tags,
+			Metadata: instance.Metadata,
+		})
+	}
+	return services
+}
@@ -0,0 +1,47 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/t0rr3sp3dr0/middleair/server"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// Harness runs a registry in-process on a loopback port, for tests that need
+// discovery without multicast.
+type Harness struct {
+	Registry *Registry
+	Options  util.Options
+}
+
+// NewHarness serves a registry on any free port until t ends.
+func NewHarness(t testing.TB) *Harness {
+	t.Helper()
+
+	e := &Harness{
+		Registry: NewRegistry(),
+	}
+
+	s, err := server.NewServer(e.Registry, util.Options{
+		Host:     "127.0.0.1",
+		Protocol: "tcp",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	e.Options = util.Options{
+		Host:     "127.0.0.1",
+		Port:     s.Port(),
+		Protocol: "tcp",
+	}
+
+	// The port is bound already, so clients may dial it before Serve accepts.
+	go s.Serve()
+	return e
+}
+
+func (e *Harness) NewClient() *Client {
+	return NewClient(e.Options)
+}
@@ -0,0 +1,22 @@
+package registry
+
+import (
+	"log"
+	"os"
+)
+
+type LoggingLevel int
+
+const (
+	LogDisabled LoggingLevel = 00
+	LogEnabled  LoggingLevel = ^0
+)
+
+var (
+	logger       = log.New(os.Stderr, "[registry] ", log.LstdFlags)
+	loggingLevel = LogDisabled
+)
+
+func SetLoggingLevel(ll LoggingLevel) {
+	loggingLevel = ll
+}
@@ -0,0 +1,308 @@
+package registry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/server"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+const (
+	DefaultPort         = 13378
+	defaultTTL          = 10 * time.Second
+	minTTL              = time.Second
+	maxTTL              = 5 * time.Minute
+	defaultWatchTimeout = 30 * time.Second
+	maxWatchTimeout     = 5 * time.Minute
+	leaseIDSize         = 16
+)
+
+type lease struct {
+	instances []*model.RegistryInstance
+	ttl       time.Duration
+	expires   time.Time
+}
+
+// Registry keeps, along with the revision of all of its instances, the one
+// of each uuid, so watches of a service wake up only when it changes.
+type Registry struct {
+	leases    map[string]*lease
+	revision  uint64
+	revisions map[string]uint64
+	changed   chan struct{}
+	mutex     *sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		leases:    make(map[string]*lease),
+		revision:  1,
+		revisions: make(map[string]uint64),
+		changed:   make(chan struct{}),
+		mutex:     &sync.Mutex{},
+	}
+}
+
+func (e *Registry) Registry() []*server.Service {
+	services := []*server.Service{
+		&server.Service{
+			Interface: reflect.TypeOf((*model.RegisterRequest)(nil)),
+			Handle:    e.register,
+			Response:  reflect.TypeOf((*model.RegisterResponse)(nil)),
+		},
+		&server.Service{
+			Interface: reflect.TypeOf((*model.HeartbeatRequest)(nil)),
+			Handle:    e.heartbeat,
+			Response:  reflect.TypeOf((*model.HeartbeatResponse)(nil)),
+		},
+		&server.Service{
+			Interface: reflect.TypeOf((*model.DeregisterRequest)(nil)),
+			Handle:    e.deregister,
+			Response:  reflect.TypeOf((*model.DeregisterResponse)(nil)),
+		},
+		&server.Service{
+			Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+			Handle:    e.lookup,
+			Response:  reflect.TypeOf((*model.LookupResponse)(nil)),
+		},
+		&server.Service{
+			Interface: reflect.TypeOf((*model.WatchRequest)(nil)),
+			Handle:    e.watch,
+			Response:  reflect.TypeOf((*model.WatchResponse)(nil)),
+		},
+	}
+
+	return services
+}
+
+func (e *Registry) Tags() []string {
+	return []string{}
+}
+
+// Serve hands every accepted connection to its own invoker until the
+// listener cannot be set up.
+func (e *Registry) Serve(options util.Options) error {
+	s, err := server.NewServer(e, options)
+	if err != nil {
+		return err
+	}
+	return s.Serve()
+}
+
+func (e *Registry) register(message proto.Message) (proto.Message, error) {
+	request := message.(*model.RegisterRequest)
+	if len(request.Instances) == 0 {
+		return nil, util.ErrExpectationFailed
+	}
+	for _, instance := range request.Instances {
+		if instance.Uuid == "" || instance.Host == "" || instance.Port == 0 || instance.Port > math.MaxUint16 {
+			return nil, util.ErrExpectationFailed
+		}
+	}
+
+	id, err := newLeaseID()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := defaultTTL
+	if request.TtlMs > 0 {
+		ttl = time.Duration(request.TtlMs) * time.Millisecond
+	}
+	if ttl < minTTL {
+		ttl = minTTL
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.leases[id] = &lease{
+		instances: request.Instances,
+		ttl:       ttl,
+		expires:   time.Now().Add(ttl),
+	}
+	e.notify(request.Instances)
+
+	return &model.RegisterResponse{
+		LeaseId: id,
+		TtlMs:   uint32(ttl / time.Millisecond),
+	}, nil
+}
+
+// A zero TTL in the response tells the provider its lease is gone and it has
+// to register again.
+func (e *Registry) heartbeat(message proto.Message) (proto.Message, error) {
+	request := message.(*model.HeartbeatRequest)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := time.Now()
+	e.expire(now)
+
+	l, ok := e.leases[request.LeaseId]
+	if !ok {
+		return &model.HeartbeatResponse{}, nil
+	}
+	l.expires = now.Add(l.ttl)
+
+	return &model.HeartbeatResponse{
+		TtlMs: uint32(l.ttl / time.Millisecond),
+	}, nil
+}
+
+func (e *Registry) deregister(message proto.Message) (proto.Message, error) {
+	request := message.(*model.DeregisterRequest)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if l, ok := e.leases[request.LeaseId]; ok {
+		delete(e.leases, request.LeaseId)
+		e.notify(l.instances)
+	}
+	return &model.DeregisterResponse{}, nil
+}
+
+func (e *Registry) lookup(message proto.Message) (proto.Message, error) {
+	request := message.(*model.LookupRequest)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.expire(time.Now())
+	return &model.LookupResponse{
+		Instances: e.instances(request.Uuid),
+		Revision:  e.revisionOf(request.Uuid),
+	}, nil
+}
+
+// Watches are long polls: the response is held back until the instances of
+// the uuid move past the revision the caller has already seen or the timeout
+// runs out.
+func (e *Registry) watch(message proto.Message) (proto.Message, error) {
+	request := message.(*model.WatchRequest)
+
+	timeout := defaultWatchTimeout
+	if request.TimeoutMs > 0 {
+		timeout = time.Duration(request.TimeoutMs) * time.Millisecond
+	}
+	if timeout > maxWatchTimeout {
+		timeout = maxWatchTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for {
+		now := time.Now()
+		e.expire(now)
+		if e.revisionOf(request.Uuid) != request.Revision || !now.Before(deadline) {
+			break
+		}
+
+		wait := deadline.Sub(now)
+		for _, l := range e.leases {
+			if d := l.expires.Sub(now); d < wait {
+				wait = d
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		changed := e.changed
+		e.mutex.Unlock()
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		e.mutex.Lock()
+	}
+
+	return &model.WatchResponse{
+		Instances: e.instances(request.Uuid),
+		Revision:  e.revisionOf(request.Uuid),
+	}, nil
+}
+
+func (e *Registry) expire(now time.Time) {
+	var expired []*model.RegistryInstance
+	for id, l := range e.leases {
+		if now.After(l.expires) {
+			delete(e.leases, id)
+			expired = append(expired, l.instances...)
+		}
+	}
+	if len(expired) > 0 {
+		e.notify(expired)
+	}
+}
+
+// notify moves the revision of the registry, and of the uuids of instances,
+// forward, waking up watches.
+func (e *Registry) notify(instances []*model.RegistryInstance) {
+	e.revision++
+	for _, instance := range instances {
+		e.revisions[instance.Uuid] = e.revision
+	}
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// revisionOf returns the revision of the instances of uuid, or of all of them
+// when uuid is empty.
+func (e *Registry) revisionOf(uuid string) uint64 {
+	if uuid == "" {
+		return e.revision
+	}
+	if revision, ok := e.revisions[uuid]; ok {
+		return revision
+	}
+	return 1
+}
+
+func (e *Registry) instances(uuid string) []*model.RegistryInstance {
+	ids := make([]string, 0, len(e.leases))
+	for id := range e.leases {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	instances := []*model.RegistryInstance{}
+	for _, id := range ids {
+		for _, instance := range e.leases[id].instances {
+			if uuid == "" || instance.Uuid == uuid {
+				instances = append(instances, instance)
+			}
+		}
+	}
+	return instances
+}
+
+func newLeaseID() (string, error) {
+	buf := make([]byte, leaseIDSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
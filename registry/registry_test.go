@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+func TestRegistry(t *testing.T) {
+	harness := NewHarness(t)
+
+	c := harness.NewClient()
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	updates := make(chan []bonjour.Service, 16)
+	go c.Watch(ctx, "*demo.Request", func(services []bonjour.Service) {
+		updates <- services
+	})
+	if services := <-updates; len(services) != 0 {
+		t.Fatalf("unexpected initial instances %+v", services)
+	}
+
+	// Other services coming and going do not wake the watch up.
+	other, err := c.Register([]bonjour.Service{
+		{UUID: "*demo.Other", Provider: bonjour.Provider{Port: 1338}},
+	}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	lease, err := c.Register([]bonjour.Service{
+		{UUID: "*demo.Request", Provider: bonjour.Provider{Port: 1337}, Tags: []string{"fast"}},
+		{UUID: "*demo.Request", Provider: bonjour.Provider{Port: 1337, Network: "udp"}},
+	}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	services := <-updates
+	if len(services) != 2 || services[0].Provider.Address() != "127.0.0.1:1337" || services[1].Provider.Address() != "udp:127.0.0.1:1337" {
+		t.Fatalf("unexpected instances %+v", services)
+	}
+
+	// Heartbeats keep the lease alive well past its TTL.
+	time.Sleep(2 * time.Second)
+	if services, err := c.Resolve(ctx, "*demo.Request"); err != nil || len(services) != 2 {
+		t.Fatalf("lease expired despite heartbeats: %v %+v", err, services)
+	}
+
+	if err := lease.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lease.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if services := <-updates; len(services) != 0 {
+		t.Fatalf("instances left after close %+v", services)
+	}
+
+	// Leases without heartbeats expire on their own.
+	response := &model.RegisterResponse{}
+	if err := c.call(&model.RegisterRequest{
+		Instances: []*model.RegistryInstance{{Uuid: "*demo.Request", Host: "192.0.2.1", Port: 1337}},
+		TtlMs:     1,
+	}, response); err != nil {
+		t.Fatal(err)
+	}
+	if services := <-updates; len(services) != 1 {
+		t.Fatalf("unexpected instances %+v", services)
+	}
+	select {
+	case services := <-updates:
+		if len(services) != 0 {
+			t.Fatalf("instances left after expiry %+v", services)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease did not expire")
+	}
+	if _, err := c.Resolve(ctx, "*demo.Request"); err != util.ErrNotFound {
+		t.Fatalf("expected %v, got %v", util.ErrNotFound, err)
+	}
+}
+
+// TestResolveDeadline resolves against a registry that never answers the
+// handshake, which the deadline of the context has to cut short.
+func TestResolveDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c := NewClient(util.Options{Host: "127.0.0.1", Port: uint16(ln.Addr().(*net.TCPAddr).Port)})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.Resolve(ctx, "*demo.Request"); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("resolve took %v", elapsed)
+	}
+}
@@ -0,0 +1,41 @@
+package resolver
+
+import (
+	"context"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+type BonjourResolver struct {
+	timeout time.Duration
+}
+
+// A zero timeout waits for announcements for as long as ctx allows.
+func NewBonjourResolver(timeout time.Duration) *BonjourResolver {
+	return &BonjourResolver{
+		timeout: timeout,
+	}
+}
+
+func (e *BonjourResolver) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	if services := bonjour.InstancesOfService(uuid); len(services) > 0 {
+		return services, nil
+	}
+
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	services, err := bonjour.WaitForService(ctx, uuid)
+	if err != nil {
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			return nil, util.ErrNotFound
+		}
+		return nil, err
+	}
+	return services, nil
+}
@@ -0,0 +1,57 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// DNSResolver looks up _<uuid>._tcp.<domain> SRV records, where the UUID is
+// lower-cased and every character outside [a-z0-9] becomes a hyphen, so
+// *demo.Request is served by _demo-request._tcp.<domain>.
+type DNSResolver struct {
+	domain   string
+	resolver *net.Resolver
+}
+
+func NewDNSResolver(domain string) *DNSResolver {
+	return &DNSResolver{
+		domain:   domain,
+		resolver: net.DefaultResolver,
+	}
+}
+
+func (e *DNSResolver) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	_, records, err := e.resolver.LookupSRV(ctx, serviceName(uuid), "tcp", e.domain)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, util.ErrNotFound
+		}
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, util.ErrNotFound
+	}
+
+	services := make([]bonjour.Service, 0, len(records))
+	for _, record := range records {
+		services = append(services, bonjour.Service{
+			UUID:     uuid,
+			Provider: provider(strings.TrimSuffix(record.Target, "."), record.Port),
+		})
+	}
+	return services, nil
+}
+
+func serviceName(uuid string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(uuid))
+	return strings.Trim(name, "-")
+}
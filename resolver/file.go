@@ -0,0 +1,153 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	filePollInterval = time.Second
+)
+
+type fileEntry struct {
+	Network  string            `json:"network" yaml:"network"`
+	Host     string            `json:"host" yaml:"host"`
+	Port     uint16            `json:"port" yaml:"port"`
+	Tags     []string          `json:"tags" yaml:"tags"`
+	Metadata map[string]string `json:"metadata" yaml:"metadata"`
+}
+
+// FileResolver serves a JSON or YAML file mapping UUIDs to instances. The
+// file is polled for changes every filePollInterval until Close, while
+// lookups are served from its last parse.
+type FileResolver struct {
+	path     string
+	modTime  time.Time
+	size     int64
+	services map[string][]bonjour.Service
+	stop     chan struct{}
+	once     *sync.Once
+	mutex    *sync.RWMutex
+}
+
+func NewFileResolver(path string) (*FileResolver, error) {
+	e := &FileResolver{
+		path:  path,
+		stop:  make(chan struct{}),
+		once:  &sync.Once{},
+		mutex: &sync.RWMutex{},
+	}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+
+	go e.poll()
+	return e, nil
+}
+
+func (e *FileResolver) Close() error {
+	e.once.Do(func() {
+		close(e.stop)
+	})
+	return nil
+}
+
+func (e *FileResolver) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return lookup(e.services, uuid)
+}
+
+func (e *FileResolver) poll() {
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.stop:
+			return
+		}
+
+		// A broken edit keeps the last good contents until the file is fixed.
+		e.reload()
+	}
+}
+
+// reload parses the file again if it changed since last time.
+func (e *FileResolver) reload() error {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.RLock()
+	unchanged := e.services != nil && info.ModTime().Equal(e.modTime) && info.Size() == e.size
+	e.mutex.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(e.path)
+	if err != nil {
+		return err
+	}
+	services, err := parseFile(e.path, data)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.services = services
+	e.modTime = info.ModTime()
+	e.size = info.Size()
+	return nil
+}
+
+func parseFile(path string, data []byte) (map[string][]bonjour.Service, error) {
+	entries := make(map[string][]fileEntry)
+	if filepath.Ext(path) == ".json" {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+	} else if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	services := make(map[string][]bonjour.Service, len(entries))
+	for uuid, instances := range entries {
+		for _, instance := range instances {
+			p := provider(instance.Host, instance.Port)
+			switch instance.Network {
+			case "", "tcp":
+
+			case "udp":
+				p.Network = instance.Network
+
+			default:
+				p = bonjour.Provider{
+					Host:    instance.Host,
+					Network: instance.Network,
+				}
+			}
+			services[uuid] = append(services[uuid], bonjour.Service{
+				UUID:     uuid,
+				Provider: p,
+				Tags:     instance.Tags,
+				Metadata: instance.Metadata,
+			})
+		}
+	}
+	return services, nil
+}
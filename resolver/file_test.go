@@ -0,0 +1,69 @@
+package resolver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileResolverReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "services.yaml")
+	if err := ioutil.WriteFile(path, []byte(`
+"*demo.Request":
+  - host: fe80::1%eth0
+    port: 1337
+    tags: [fast]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewFileResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	services, err := e.Resolve(context.Background(), "*demo.Request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Provider.Host != "fe80::1" || services[0].Provider.Zone != "eth0" || services[0].Tags[0] != "fast" {
+		t.Fatalf("unexpected services %+v", services)
+	}
+	if _, err := e.Resolve(context.Background(), "*demo.Other"); err == nil {
+		t.Fatal("expected an error for an unknown UUID")
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`"*": [{host: 10.0.0.1, port: 80}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes are picked up by polling, not by lookups.
+	deadline := time.Now().Add(3 * filePollInterval)
+	for {
+		services, err = e.Resolve(context.Background(), "*demo.Other")
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].UUID != "*demo.Other" || services[0].Provider.Address() != "10.0.0.1:80" {
+		t.Fatalf("unexpected services %+v", services)
+	}
+}
@@ -0,0 +1,69 @@
+package resolver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+var (
+	localServices      = make(map[*bonjour.Service]struct{})
+	localServicesMutex = &sync.RWMutex{}
+)
+
+// RegisterLocal makes service known to the local resolvers of the process,
+// the way servers on unix sockets and mem pipes offer theirs.
+func RegisterLocal(service *bonjour.Service) {
+	localServicesMutex.Lock()
+	defer localServicesMutex.Unlock()
+
+	localServices[service] = struct{}{}
+}
+
+func UnregisterLocal(service *bonjour.Service) {
+	localServicesMutex.Lock()
+	defer localServicesMutex.Unlock()
+
+	delete(localServices, service)
+}
+
+// SetLocalMetadata sets a metadata entry of service, as bonjour.SetMetadata
+// does for announced ones.
+func SetLocalMetadata(service *bonjour.Service, key string, value string) {
+	localServicesMutex.Lock()
+	defer localServicesMutex.Unlock()
+
+	metadata := make(map[string]string, len(service.Metadata)+1)
+	for k, v := range service.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	service.Metadata = metadata
+}
+
+// LocalResolver finds the services registered within the process, without
+// touching the network.
+type LocalResolver struct {
+}
+
+func NewLocalResolver() *LocalResolver {
+	return &LocalResolver{}
+}
+
+func (e *LocalResolver) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	localServicesMutex.RLock()
+	defer localServicesMutex.RUnlock()
+
+	var services []bonjour.Service
+	for service := range localServices {
+		if service.UUID == uuid {
+			services = append(services, *service)
+		}
+	}
+	if len(services) == 0 {
+		return nil, util.ErrNotFound
+	}
+	return services, nil
+}
@@ -0,0 +1,74 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+const (
+	wildcardService = "*"
+)
+
+type Resolver interface {
+	Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error)
+}
+
+type PriorityResolver struct {
+	resolvers []Resolver
+}
+
+func NewPriorityResolver(resolvers ...Resolver) *PriorityResolver {
+	return &PriorityResolver{
+		resolvers: resolvers,
+	}
+}
+
+// Resolve asks the resolvers in order. Each is given an even share of what is
+// left until the deadline of ctx, so that those waiting for announcements do
+// not leave the rest an expired one.
+func (e *PriorityResolver) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	var ret error
+	for i, resolver := range e.resolvers {
+		rctx, cancel := share(ctx, len(e.resolvers)-i)
+		services, err := resolver.Resolve(rctx, uuid)
+		cancel()
+		if err != nil {
+			if err != util.ErrNotFound && ret == nil {
+				ret = err
+			}
+			continue
+		}
+		if len(services) > 0 {
+			return services, nil
+		}
+	}
+
+	if ret != nil {
+		return nil, ret
+	}
+	return nil, util.ErrNotFound
+}
+
+// share bounds ctx to one of n parts of the time it has left.
+func share(ctx context.Context, n int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || n <= 1 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(n))
+}
+
+func provider(host string, port uint16) bonjour.Provider {
+	p := bonjour.Provider{
+		Host: host,
+		Port: port,
+	}
+	if i := strings.LastIndexByte(host, '%'); i >= 0 {
+		p.Host, p.Zone = host[:i], host[i+1:]
+	}
+	return p
+}
@@ -0,0 +1,42 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// waitingResolver finds nothing once ctx is done, as the bonjour one does.
+type waitingResolver struct{}
+
+func (e *waitingResolver) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	<-ctx.Done()
+	return nil, util.ErrNotFound
+}
+
+type checkingResolver struct {
+	err error
+}
+
+func (e *checkingResolver) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	e.err = ctx.Err()
+	return []bonjour.Service{{UUID: uuid}}, nil
+}
+
+func TestPriorityResolverSharesDeadline(t *testing.T) {
+	last := &checkingResolver{}
+	e := NewPriorityResolver(&waitingResolver{}, &waitingResolver{}, last)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	services, err := e.Resolve(ctx, "*demo.Request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || last.err != nil {
+		t.Fatalf("last resolver got %v with %v", services, last.err)
+	}
+}
@@ -0,0 +1,46 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+type StaticResolver struct {
+	services map[string][]bonjour.Service
+}
+
+// Services listed under "*" are returned for any UUID without entries of its own.
+func NewStaticResolver(services map[string][]bonjour.Service) *StaticResolver {
+	e := &StaticResolver{
+		services: make(map[string][]bonjour.Service, len(services)),
+	}
+	for uuid, instances := range services {
+		e.services[uuid] = append([]bonjour.Service{}, instances...)
+	}
+	return e
+}
+
+func (e *StaticResolver) Resolve(ctx context.Context, uuid string) ([]bonjour.Service, error) {
+	return lookup(e.services, uuid)
+}
+
+func lookup(services map[string][]bonjour.Service, uuid string) ([]bonjour.Service, error) {
+	instances, ok := services[uuid]
+	if !ok {
+		instances = services[wildcardService]
+	}
+	if len(instances) == 0 {
+		return nil, util.ErrNotFound
+	}
+
+	ret := make([]bonjour.Service, 0, len(instances))
+	for _, instance := range instances {
+		if instance.UUID == "" || instance.UUID == wildcardService {
+			instance.UUID = uuid
+		}
+		ret = append(ret, instance)
+	}
+	return ret, nil
+}
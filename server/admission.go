@@ -0,0 +1,152 @@
+package server
+
+import (
+	"math"
+	"net"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/crypto"
+)
+
+const (
+	defaultHandshakeTimeout = 10 * time.Second
+	maxBuckets              = 4096
+	// Connections rejected beyond these many at once are closed unanswered.
+	maxRejects = 64
+)
+
+// bucket holds the connections an IP may still open right away, refilled
+// over time up to the burst.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// admit reserves a connection and a handshake for conn, unless the port is
+// over capacity.
+func (e *sharedListener) admit(conn net.Conn) bool {
+	if e.limits == nil {
+		return true
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.limits.MaxConnections > 0 && e.conns >= e.limits.MaxConnections {
+		return false
+	}
+	if e.limits.MaxHandshakes > 0 && e.handshakes >= e.limits.MaxHandshakes {
+		return false
+	}
+	if e.limits.RatePerIP > 0 && !e.take(conn.RemoteAddr(), time.Now()) {
+		return false
+	}
+
+	e.conns++
+	e.handshakes++
+	return true
+}
+
+func (e *sharedListener) handshaken() {
+	if e.limits == nil {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.handshakes--
+}
+
+func (e *sharedListener) disconnected() {
+	if e.limits == nil {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.conns--
+}
+
+// take spends a token of the IP of addr. Must be called under mutex.
+func (e *sharedListener) take(addr net.Addr, now time.Time) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+
+	burst := float64(e.limits.BurstPerIP)
+	if burst < 1 {
+		burst = 1
+	}
+	refill := func(b *bucket) float64 {
+		return math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*e.limits.RatePerIP)
+	}
+
+	if len(e.buckets) >= maxBuckets {
+		for host, b := range e.buckets {
+			if refill(b) == burst {
+				delete(e.buckets, host)
+			}
+		}
+	}
+
+	b, ok := e.buckets[host]
+	if !ok {
+		b = &bucket{
+			tokens: burst,
+			last:   now,
+		}
+		e.buckets[host] = b
+	}
+	b.tokens = refill(b)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// reject answers conn with 503 without holding up the accepting handler, or
+// closes it right away if maxRejects are being answered already.
+func (e *sharedListener) reject(conn net.Conn) {
+	if loggingLevel&LogEnabled != LogDisabled {
+		logger.Println(conn.RemoteAddr(), "over capacity")
+	}
+
+	e.mutex.Lock()
+	if e.rejects >= maxRejects {
+		e.mutex.Unlock()
+		conn.Close()
+		return
+	}
+	e.rejects++
+	e.mutex.Unlock()
+
+	conn.SetDeadline(time.Now().Add(e.handshakeTimeout()))
+
+	go func() {
+		crypto.Reject(conn, 503%256)
+
+		e.mutex.Lock()
+		e.rejects--
+		e.mutex.Unlock()
+	}()
+}
+
+func (e *sharedListener) handshakeTimeout() time.Duration {
+	if e.limits == nil || e.limits.HandshakeTimeout == 0 {
+		return defaultHandshakeTimeout
+	}
+	return e.limits.HandshakeTimeout
+}
+
+func (e *sharedListener) idleTimeout() time.Duration {
+	if e.limits == nil {
+		return 0
+	}
+	return e.limits.IdleTimeout
+}
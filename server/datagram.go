@@ -0,0 +1,165 @@
+package server
+
+import (
+	"net"
+	"sync"
+
+	"github.com/t0rr3sp3dr0/middleair/crypto"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+const (
+	maxDatagramSize = 1 << 16
+	// Datagrams arriving while these many are handled are dropped.
+	maxDatagramHandlers = 256
+)
+
+// newDatagramServer listens for udp datagrams and, unless they are sealed
+// with a pre-shared key, for tcp handshakes on the same port to key them.
+func newDatagramServer(sp ServerProxy, options util.Options) (*Server, error) {
+	if options.MTU == 0 {
+		options.MTU = util.DefaultMTU
+	}
+
+	keyring := crypto.NewKeyring()
+	if options.PreSharedKey != nil {
+		c, err := crypto.NewDatagramCipher(options.PreSharedKey, crypto.ToClient)
+		if err != nil {
+			return nil, err
+		}
+		keyring.Add(c)
+	}
+
+	pc, err := util.ListenPacket(options)
+	if err != nil {
+		return nil, err
+	}
+	options.Port = uint16(pc.LocalAddr().(*net.UDPAddr).Port)
+
+	h := newHealth()
+	e := &Server{
+		sp:         sp,
+		options:    options,
+		health:     h,
+		packetConn: pc,
+		keyring:    keyring,
+		datagrams:  &sync.WaitGroup{},
+		handlers:   make(chan struct{}, maxDatagramHandlers),
+		invoker:    newInvoker(sp, nil, nil, h),
+		invokers:   make(map[*Invoker]struct{}),
+		mutex:      &sync.Mutex{},
+	}
+
+	if options.PreSharedKey == nil {
+		stream := options
+		stream.Protocol = "tcp"
+		ln, err := util.Listen(stream)
+		if err != nil {
+			pc.Close()
+			return nil, err
+		}
+		e.listener = newSharedListener(ln, ln.Addr().String(), options.ConnLimits)
+		e.listener.keyring = keyring
+		e.listener.refs++
+	}
+	return e, nil
+}
+
+// serveDatagrams opens every datagram read and handles those that open in a
+// goroutine of their own, up to maxDatagramHandlers at once. Datagrams that
+// do not open are dropped unanswered, as their sender cannot be told apart
+// from a forger.
+func (e *Server) serveDatagrams() error {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := e.packetConn.ReadFrom(buf)
+		if err != nil {
+			if e.stopped() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if n > e.options.MTU {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(addr, util.ErrPayloadTooLarge)
+			}
+			continue
+		}
+
+		data, c, err := e.keyring.Open(buf[:n])
+		if err != nil {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(addr, err)
+			}
+			continue
+		}
+
+		select {
+		case e.handlers <- struct{}{}:
+		default:
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(addr, util.ErrServiceUnavailable)
+			}
+			continue
+		}
+		if !e.beginDatagram() {
+			<-e.handlers
+			return ErrServerClosed
+		}
+		go func() {
+			defer func() {
+				<-e.handlers
+				e.datagrams.Done()
+			}()
+			e.handleDatagram(addr, data, c)
+		}()
+	}
+}
+
+// handleDatagram answers a request opened with c unless it is one-way.
+func (e *Server) handleDatagram(addr net.Addr, data []byte, c *crypto.DatagramCipher) {
+	flags, id, message, err := util.ParseDatagram(data)
+	if err != nil {
+		if loggingLevel&LogEnabled != LogDisabled {
+			logger.Println(addr, err)
+		}
+		return
+	}
+
+	res, err := e.invoker.respond(message)
+	if err != nil {
+		if loggingLevel&LogEnabled != LogDisabled {
+			logger.Println(addr, err)
+		}
+		return
+	}
+	if flags&util.OneWay != 0 {
+		return
+	}
+
+	reply, err := c.Seal(util.Datagram(0, id, res))
+	if err == nil && len(reply) > e.options.MTU {
+		if res, err = errorResponse(413, util.ErrPayloadTooLarge); err == nil {
+			reply, err = c.Seal(util.Datagram(0, id, res))
+		}
+	}
+	if err == nil {
+		_, err = e.packetConn.WriteTo(reply, addr)
+	}
+	if err != nil {
+		if loggingLevel&LogEnabled != LogDisabled {
+			logger.Println(addr, err)
+		}
+	}
+}
+
+func (e *Server) beginDatagram() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return false
+	}
+	e.datagrams.Add(1)
+	return true
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/resolver"
+)
+
+const (
+	Serving    = model.HealthCheckResponse_SERVING
+	NotServing = model.HealthCheckResponse_NOT_SERVING
+)
+
+// health holds the serving statuses a server reports and announces, and the
+// services announced with them.
+type health struct {
+	statuses   map[string]model.HealthCheckResponse_ServingStatus
+	advertised map[*bonjour.Service]struct{}
+	mutex      *sync.RWMutex
+}
+
+var (
+	// invokerHealth is shared by the invokers made with NewInvoker.
+	invokerHealth = newHealth()
+)
+
+func newHealth() *health {
+	return &health{
+		statuses:   make(map[string]model.HealthCheckResponse_ServingStatus),
+		advertised: make(map[*bonjour.Service]struct{}),
+		mutex:      &sync.RWMutex{},
+	}
+}
+
+// SetServingStatus sets the status reported and announced for a service by
+// invokers made with NewInvoker. Servers have their own, set through
+// Server.SetServingStatus.
+func SetServingStatus(service string, status model.HealthCheckResponse_ServingStatus) {
+	invokerHealth.set(service, status)
+}
+
+// SetServingStatus sets the status reported and announced for a service. The
+// empty service stands for the whole server, which being NOT_SERVING
+// overrides the status of every service.
+func (e *Server) SetServingStatus(service string, status model.HealthCheckResponse_ServingStatus) {
+	e.health.set(service, status)
+}
+
+func (e *health) set(service string, status model.HealthCheckResponse_ServingStatus) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.statuses[service] = status
+	for s := range e.advertised {
+		if service != "" && s.UUID != service {
+			continue
+		}
+		if local(s) {
+			resolver.SetLocalMetadata(s, bonjour.MetadataHealth, e.status(s.UUID).String())
+			continue
+		}
+		if err := bonjour.SetMetadata(s, bonjour.MetadataHealth, e.status(s.UUID).String()); err != nil {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(err)
+			}
+		}
+	}
+}
+
+// status must be called with mutex held.
+func (e *health) status(service string) model.HealthCheckResponse_ServingStatus {
+	if e.statuses[""] == NotServing {
+		return NotServing
+	}
+	if status, ok := e.statuses[service]; ok {
+		return status
+	}
+	return Serving
+}
+
+func (e *health) advertise(service *bonjour.Service) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	service.Metadata = map[string]string{
+		bonjour.MetadataHealth: e.status(service.UUID).String(),
+	}
+	if service.Provider.Network != "" {
+		service.Metadata[bonjour.MetadataNetwork] = service.Provider.Network
+	}
+	if local(service) {
+		resolver.RegisterLocal(service)
+	} else if err := bonjour.RegisterService(service); err != nil {
+		return err
+	}
+	e.advertised[service] = struct{}{}
+	return nil
+}
+
+func (e *health) withdraw(service *bonjour.Service) {
+	e.mutex.Lock()
+	delete(e.advertised, service)
+	e.mutex.Unlock()
+
+	if local(service) {
+		resolver.UnregisterLocal(service)
+		return
+	}
+	bonjour.UnregisterService(service)
+}
+
+// local tells whether service is on a unix socket or mem pipe, which only the
+// process itself can reach.
+func local(service *bonjour.Service) bool {
+	return service.Provider.Network != "" && service.Provider.Network != "udp"
+}
+
+func (e *Invoker) healthService() *Service {
+	return &Service{
+		Interface: reflect.TypeOf((*model.HealthCheckRequest)(nil)),
+		Handle:    e.checkHealth,
+		Response:  reflect.TypeOf((*model.HealthCheckResponse)(nil)),
+	}
+}
+
+func (e *Invoker) checkHealth(message proto.Message) (proto.Message, error) {
+	request := message.(*model.HealthCheckRequest)
+
+	if request.Service != "" {
+		known := false
+		for _, service := range e.registry() {
+			if service.Interface.String() == request.Service {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return &model.HealthCheckResponse{
+				Status: model.HealthCheckResponse_SERVICE_UNKNOWN,
+			}, nil
+		}
+	}
+
+	e.health.mutex.RLock()
+	defer e.health.mutex.RUnlock()
+
+	return &model.HealthCheckResponse{
+		Status: e.health.status(request.Service),
+	}, nil
+}
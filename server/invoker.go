@@ -1,8 +1,14 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"net"
 	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/t0rr3sp3dr0/middleair/bonjour"
@@ -15,135 +21,347 @@ type Invoker struct {
 	services []*bonjour.Service
 	mashaler *util.Mashaler
 	srh      *ServerRequestHandler
+	health   *health
+	limits   *Limits
+	closing  bool
+	busy     bool
+	idle     chan struct{}
+	mutex    *sync.Mutex
 }
 
 func NewInvoker(sp ServerProxy, options util.Options) (*Invoker, error) {
-	mashaler, err := util.NewMashaler()
+	services, err := invokerHealth.advertiseAll(sp, options)
 	if err != nil {
 		return nil, err
 	}
 
 	srh, err := NewServerRequestHandler(options)
 	if err != nil {
+		invokerHealth.withdrawAll(services)
 		return nil, err
 	}
 
+	return newInvoker(sp, srh, services, invokerHealth), nil
+}
+
+// newInvoker wraps srh, withdrawing services once done with them, and reports
+// the statuses of h.
+func newInvoker(sp ServerProxy, srh *ServerRequestHandler, services []*bonjour.Service, h *health) *Invoker {
+	return &Invoker{
+		sp:       sp,
+		services: services,
+		mashaler: &util.Mashaler{},
+		srh:      srh,
+		health:   h,
+		limits:   limitsOf(sp),
+		idle:     make(chan struct{}),
+		mutex:    &sync.Mutex{},
+	}
+}
+
+// advertiseAll announces the registry of sp as provided where options say.
+// Only tcp and udp providers are announced to peers, others are registered
+// for local discovery.
+func (e *health) advertiseAll(sp ServerProxy, options util.Options) ([]*bonjour.Service, error) {
+	provider := bonjour.Provider{
+		Port: options.Port,
+	}
+	switch options.Protocol {
+	case "tcp":
+	case "udp":
+		provider.Network = "udp"
+	default:
+		provider = bonjour.Provider{
+			Host:    options.Host,
+			Port:    options.Port,
+			Network: options.Protocol,
+		}
+	}
+
 	registry := sp.Registry()
 	tags := sp.Tags()
 	services := make([]*bonjour.Service, 0, len(registry))
 	for _, service := range registry {
 		s := &bonjour.Service{
-			UUID: service.Interface.String(),
-			Provider: bonjour.Provider{
-				Port: options.Port,
-			},
+			UUID:     service.Interface.String(),
+			Provider: provider,
+			Tags:     append([]string{}, tags...),
+		}
+		if err := e.advertise(s); err != nil {
+			e.withdrawAll(services)
+			return nil, err
 		}
-		copy(s.Tags[:], tags[:])
-		bonjour.RegisterService(s)
 		services = append(services, s)
 	}
+	return services, nil
+}
 
-	return &Invoker{
-		sp:       sp,
-		services: services,
-		mashaler: mashaler,
-		srh:      srh,
-	}, nil
+func (e *health) withdrawAll(services []*bonjour.Service) {
+	for _, service := range services {
+		e.withdraw(service)
+	}
 }
 
 func (e *Invoker) Accept(credentials []byte) error {
 	return e.srh.Accept(credentials)
 }
 
+// Shutdown withdraws the services, sending goodbyes to peers, and stops
+// accepting. The request being handled, if any, is given until ctx is done to
+// complete before the connection is closed.
+func (e *Invoker) Shutdown(ctx context.Context) error {
+	e.unregister()
+
+	e.mutex.Lock()
+	e.closing = true
+	busy := e.busy
+	e.mutex.Unlock()
+
+	var err error
+	if busy {
+		select {
+		case <-e.idle:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if cerr := e.srh.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Close stops the invoker immediately, dropping the request in flight.
+func (e *Invoker) Close() error {
+	e.unregister()
+
+	e.mutex.Lock()
+	e.closing = true
+	e.mutex.Unlock()
+
+	return e.srh.Close()
+}
+
+func (e *Invoker) unregister() {
+	e.mutex.Lock()
+	services := e.services
+	e.services = nil
+	e.mutex.Unlock()
+
+	e.health.withdrawAll(services)
+}
+
+func (e *Invoker) begin() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closing {
+		return false
+	}
+	e.busy = true
+	return true
+}
+
+func (e *Invoker) end() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.busy = false
+	if e.closing {
+		close(e.idle)
+	}
+}
+
+func (e *Invoker) stopping() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.closing
+}
+
+// registry lists the services of the server along with the built-in ones.
+func (e *Invoker) registry() []*Service {
+	return append(e.sp.Registry(), e.healthService(), e.reflectionService())
+}
+
 func (e *Invoker) Loop() error {
 	defer func() {
-		for _, service := range e.services {
-			bonjour.UnregisterService(service)
-		}
+		e.unregister()
 		if err := e.srh.Close(); err != nil {
 			if loggingLevel&LogEnabled != LogDisabled {
 				logger.Println(err)
 			}
 		}
-
-		e.sp = nil
-		e.services = nil
-		e.mashaler = nil
-		e.srh = nil
 	}()
 
 	for {
-		bytes, err := e.srh.Receive()
+		bytes, err := e.srh.Receive(e.limits.maxSize())
 		if err != nil {
-			if err == io.EOF {
+			if err == io.EOF || e.stopping() {
+				break
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				if loggingLevel&LogEnabled != LogDisabled {
+					logger.Println("closing idle connection")
+				}
 				break
 			}
-			e.srh.handleBadRequest(err)
+			if err == util.ErrPayloadTooLarge {
+				if err := e.srh.handleError(413, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := e.srh.handleBadRequest(err); err != nil {
+				return err
+			}
 			continue
 		}
 
-		message := &model.SelfDescribingMessage{}
-		if err := e.mashaler.Unmarshal(bytes, message); err != nil {
-			e.srh.handleBadRequest(err)
-			continue
+		// Requests read once shutting down are dropped rather than started.
+		if !e.begin() {
+			break
+		}
+		err = e.handle(bytes)
+		e.end()
+		if err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		var service *Service
-		var innerMessage proto.Message
-		for _, s := range e.sp.Registry() {
-			if message.TypeName == s.Interface.String() {
-				b := s.Interface.Kind() == reflect.Ptr
-				t := s.Interface
-				if b {
-					t = t.Elem()
-				}
-				v := reflect.Indirect(reflect.New(t))
-				if b {
-					v = v.Addr()
-				}
-				m := v.Interface().(proto.Message)
+func (e *Invoker) handle(bytes []byte) error {
+	res, err := e.respond(bytes)
+	if err != nil {
+		return err
+	}
+	return e.srh.Send(res)
+}
 
-				service = s
-				innerMessage = m
-				break
+// respond handles a request, returning the response to it, or the error
+// response if it fails.
+func (e *Invoker) respond(bytes []byte) ([]byte, error) {
+	if !e.limits.allowsSize(len(bytes)) {
+		return errorResponse(413, util.ErrPayloadTooLarge)
+	}
+
+	message := &model.SelfDescribingMessage{}
+	if err := e.mashaler.Unmarshal(bytes, message); err != nil {
+		return errorResponse(400, err)
+	}
+
+	var service *Service
+	var innerMessage proto.Message
+	for _, s := range e.registry() {
+		if message.TypeName == s.Interface.String() {
+			b := s.Interface.Kind() == reflect.Ptr
+			t := s.Interface
+			if b {
+				t = t.Elem()
 			}
+			v := reflect.Indirect(reflect.New(t))
+			if b {
+				v = v.Addr()
+			}
+			m := v.Interface().(proto.Message)
+
+			service = s
+			innerMessage = m
+			break
 		}
-		if service == nil {
-			return util.ErrNotFound
-		}
+	}
+	if service == nil {
+		return errorResponse(404, util.ErrNotFound)
+	}
 
-		if err := e.mashaler.Unmarshal(message.MessageData, innerMessage); err != nil {
-			e.srh.handleBadRequest(err)
-			continue
+	if err := e.mashaler.Unmarshal(message.MessageData, innerMessage); err != nil {
+		return errorResponse(400, err)
+	}
+
+	if !e.limits.acquire() {
+		return errorResponse(503, util.ErrServiceUnavailable)
+	}
+	response, err := call(context.Background(), e.limits, service, innerMessage)
+	if err == util.ErrGatewayTimeout {
+		return errorResponse(504, err)
+	}
+	if err != nil {
+		return errorResponse(500, err)
+	}
+
+	switch response.(type) {
+	case *model.ErrorResponse:
+		data, err := e.mashaler.Marshal(response)
+		if err != nil {
+			return errorResponse(500, err)
 		}
+		return data, nil
 
-		response, err := service.Handle(innerMessage)
+	default:
+		data, err := util.SelfDescribingMessage(response)
 		if err != nil {
-			e.srh.handleInternalServerError(err)
-			continue
+			return errorResponse(500, err)
 		}
+		return data, nil
+	}
+}
 
-		var res []byte
-		switch response.(type) {
-		case *model.ErrorResponse:
-			data, err := e.mashaler.Marshal(response)
-			if err != nil {
-				e.srh.handleInternalServerError(err)
-			}
-			res = data
+// Call runs the handler of service within the limits of sp, as invokers do,
+// for servers reached over other transports.
+func Call(sp ServerProxy, service *Service, message proto.Message) (proto.Message, error) {
+	return CallContext(context.Background(), sp, service, message)
+}
 
-		default:
-			data, err := util.SelfDescribingMessage(response)
-			if err != nil {
-				e.srh.handleInternalServerError(err)
-				continue
+// CallContext is Call that stops waiting for the handler, failing with the
+// error of ctx, once ctx is done.
+func CallContext(ctx context.Context, sp ServerProxy, service *Service, message proto.Message) (proto.Message, error) {
+	limits := limitsOf(sp)
+	if !limits.acquire() {
+		return nil, util.ErrServiceUnavailable
+	}
+	return call(ctx, limits, service, message)
+}
+
+// call runs the handler, turning panics into errors. Handlers that outlive
+// their timeout, or ctx, keep their slot until they return, as they cannot be
+// stopped.
+func call(ctx context.Context, limits *Limits, service *Service, message proto.Message) (proto.Message, error) {
+	type result struct {
+		response proto.Message
+		err      error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		defer limits.release()
+		defer func() {
+			if r := recover(); r != nil {
+				if loggingLevel&LogEnabled != LogDisabled {
+					logger.Printf("%v panicked: %v\n%s", service.Interface, r, debug.Stack())
+				}
+				ch <- result{err: fmt.Errorf("Handler Panicked: %v", r)}
 			}
-			res = data
-		}
+		}()
 
-		if err := e.srh.Send(res); err != nil {
-			return err
-		}
+		response, err := service.Handle(message)
+		ch <- result{response: response, err: err}
+	}()
+
+	var timeout <-chan time.Time
+	if d := limits.timeout(); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case r := <-ch:
+		return r.response, r.err
+	case <-timeout:
+		return nil, util.ErrGatewayTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	return nil
 }
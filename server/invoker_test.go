@@ -0,0 +1,711 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/client"
+	"github.com/t0rr3sp3dr0/middleair/crypto"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+	"github.com/t0rr3sp3dr0/middleair/resolver"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+type slowServer struct {
+	started chan struct{}
+}
+
+func (e *slowServer) Registry() []*Service {
+	return []*Service{
+		&Service{
+			Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+			Handle: func(message proto.Message) (proto.Message, error) {
+				close(e.started)
+				time.Sleep(500 * time.Millisecond)
+				return &model.LookupResponse{Revision: 1}, nil
+			},
+		},
+	}
+}
+
+func (e *slowServer) Tags() []string {
+	return []string{}
+}
+
+type limitedServer struct {
+	limits Limits
+}
+
+func (e *limitedServer) Registry() []*Service {
+	return []*Service{
+		&Service{
+			Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+			Handle: func(message proto.Message) (proto.Message, error) {
+				panic("boom")
+			},
+		},
+		&Service{
+			Interface: reflect.TypeOf((*model.WatchRequest)(nil)),
+			Handle: func(message proto.Message) (proto.Message, error) {
+				time.Sleep(time.Second)
+				return &model.WatchResponse{}, nil
+			},
+		},
+	}
+}
+
+func (e *limitedServer) Tags() []string {
+	return []string{}
+}
+
+func (e *limitedServer) Limits() *Limits {
+	return &e.limits
+}
+
+func freePort(t *testing.T) uint16 {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return uint16(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// serve accepts a single client connection on a fresh port and runs the loop.
+func serve(t *testing.T, sp ServerProxy) (*Invoker, *client.ClientProxy) {
+	options := util.Options{
+		Host:     "127.0.0.1",
+		Port:     freePort(t),
+		Protocol: "tcp",
+	}
+	invoker, err := NewInvoker(sp, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		accepted <- invoker.Accept(nil)
+	}()
+	proxy, err := client.NewClientProxy(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-accepted; err != nil {
+		t.Fatal(err)
+	}
+
+	go invoker.Loop()
+	return invoker, proxy
+}
+
+func TestInvokerShutdownDrains(t *testing.T) {
+	sp := &slowServer{started: make(chan struct{})}
+	invoker, proxy := serve(t, sp)
+	defer proxy.Close()
+
+	invoked := make(chan error, 1)
+	response := &model.LookupResponse{}
+	go func() {
+		invoked <- proxy.Invoke(&model.LookupRequest{}, response)
+	}()
+	<-sp.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := invoker.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-invoked; err != nil || response.Revision != 1 {
+		t.Fatalf("in-flight request lost: %v %+v", err, response)
+	}
+	if err := proxy.Invoke(&model.LookupRequest{}, response); err == nil {
+		t.Fatal("connection still open after shutdown")
+	}
+}
+
+func TestInvokerCloseStopsAccepting(t *testing.T) {
+	invoker, err := NewInvoker(&slowServer{}, util.Options{
+		Port:     freePort(t),
+		Protocol: "tcp",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		accepted <- invoker.Accept(nil)
+	}()
+	if err := invoker.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-accepted:
+		if err == nil {
+			t.Fatal("accept succeeded after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("accept still blocked after close")
+	}
+}
+
+func TestInvokerLimits(t *testing.T) {
+	invoker, proxy := serve(t, &limitedServer{
+		limits: Limits{
+			MaxConcurrentHandlers: 1,
+			MaxRequestSize:        128,
+			HandlerTimeout:        100 * time.Millisecond,
+		},
+	})
+	defer invoker.Close()
+	defer proxy.Close()
+
+	// Random data compresses little, so its frame alone is over the limit.
+	noise := make([]byte, 32<<10)
+	rand.Read(noise)
+
+	for _, c := range []struct {
+		req  proto.Message
+		code string
+	}{
+		{&model.LookupRequest{}, "500:"},
+		{&model.LookupRequest{Uuid: strings.Repeat("x", 256)}, "413:"},
+		{&model.LookupRequest{Uuid: hex.EncodeToString(noise)}, "413:"},
+		{&model.WatchRequest{}, "504:"},
+		// The handler that timed out still holds the only slot.
+		{&model.WatchRequest{}, "503:"},
+	} {
+		err := proxy.Invoke(c.req, &model.WatchResponse{})
+		if err == nil || !strings.HasPrefix(err.Error(), c.code) {
+			t.Fatalf("%T: err = %v, want %s", c.req, err, c.code)
+		}
+	}
+}
+
+func TestInvokerHealth(t *testing.T) {
+	invoker, proxy := serve(t, &slowServer{})
+	defer invoker.Close()
+	defer proxy.Close()
+
+	uuid := reflect.TypeOf((*model.LookupRequest)(nil)).String()
+	check := func(service string, want model.HealthCheckResponse_ServingStatus) {
+		response := &model.HealthCheckResponse{}
+		if err := proxy.Invoke(&model.HealthCheckRequest{Service: service}, response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Status != want {
+			t.Fatalf("%q: status = %v, want %v", service, response.Status, want)
+		}
+	}
+
+	check("", Serving)
+	check(uuid, Serving)
+	check("*proto.Unknown", model.HealthCheckResponse_SERVICE_UNKNOWN)
+
+	SetServingStatus(uuid, NotServing)
+	defer SetServingStatus(uuid, Serving)
+	check(uuid, NotServing)
+	check("", Serving)
+
+	for _, service := range invoker.services {
+		if service.Metadata[bonjour.MetadataHealth] != NotServing.String() {
+			t.Fatalf("announced metadata %v", service.Metadata)
+		}
+	}
+}
+
+func TestInvokerReflection(t *testing.T) {
+	invoker, proxy := serve(t, &slowServer{})
+	defer invoker.Close()
+	defer proxy.Close()
+
+	response := &model.ServerReflectionResponse{}
+	if err := proxy.Invoke(&model.ServerReflectionRequest{}, response); err != nil {
+		t.Fatal(err)
+	}
+
+	types := make(map[string]string)
+	for _, service := range response.Services {
+		types[service.Uuid] = service.RequestType + " " + service.ResponseType
+	}
+	want := map[string]string{
+		"*proto.LookupRequest":           "proto.LookupRequest ",
+		"*proto.HealthCheckRequest":      "proto.HealthCheckRequest proto.HealthCheckResponse",
+		"*proto.ServerReflectionRequest": "proto.ServerReflectionRequest proto.ServerReflectionResponse",
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("services = %v, want %v", types, want)
+	}
+
+	files := make(map[string]bool)
+	for _, data := range response.FileDescriptors {
+		fd := &descriptor.FileDescriptorProto{}
+		if err := proto.Unmarshal(data, fd); err != nil {
+			t.Fatal(err)
+		}
+		for _, dependency := range fd.Dependency {
+			if !files[dependency] {
+				t.Fatalf("%s listed before its dependency %s", fd.GetName(), dependency)
+			}
+		}
+		files[fd.GetName()] = true
+	}
+	for _, name := range []string{"registry.proto", "health.proto", "reflection.proto"} {
+		if !files[name] {
+			t.Fatalf("missing descriptor for %s in %v", name, files)
+		}
+	}
+}
+
+func TestServerConnLimits(t *testing.T) {
+	options := util.Options{
+		Host:     "127.0.0.1",
+		Port:     freePort(t),
+		Protocol: "tcp",
+		ConnLimits: &util.ConnLimits{
+			MaxConnections: 1,
+			IdleTimeout:    time.Second,
+		},
+	}
+	accept := func() *Invoker {
+		invoker, err := NewInvoker(&slowServer{}, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			if err := invoker.Accept(nil); err == nil {
+				invoker.Loop()
+			}
+		}()
+		return invoker
+	}
+
+	first := accept()
+	defer first.Close()
+	proxy, err := client.NewClientProxy(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	second := accept()
+	defer second.Close()
+	if _, err := client.NewClientProxy(options); err != util.ErrServiceUnavailable {
+		t.Fatalf("expected %v, got %v", util.ErrServiceUnavailable, err)
+	}
+
+	// The idle connection is dropped, making room for another.
+	time.Sleep(1500 * time.Millisecond)
+	if err := proxy.Invoke(&model.HealthCheckRequest{}, &model.HealthCheckResponse{}); err == nil {
+		t.Fatal("idle connection still open")
+	}
+	another, err := client.NewClientProxy(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	another.Close()
+}
+
+func TestRejectBudget(t *testing.T) {
+	listener := newSharedListener(nil, "", &util.ConnLimits{})
+
+	conn, peer := net.Pipe()
+	listener.reject(conn)
+	if listener.rejects != 1 {
+		t.Fatalf("%d rejects in flight, want 1", listener.rejects)
+	}
+
+	// Over budget, connections are closed without reading their key.
+	listener.mutex.Lock()
+	listener.rejects = maxRejects
+	listener.mutex.Unlock()
+	over, overPeer := net.Pipe()
+	listener.reject(over)
+	if _, err := overPeer.Read(make([]byte, 1)); err == nil {
+		t.Fatal("connection over the reject budget left open")
+	}
+
+	peer.Close()
+	for deadline := time.Now().Add(time.Second); ; {
+		listener.mutex.Lock()
+		rejects := listener.rejects
+		listener.mutex.Unlock()
+		if rejects == maxRejects-1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d rejects in flight, want %d", rejects, maxRejects-1)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer(t *testing.T) {
+	first, err := NewServer(&slowServer{}, util.Options{Host: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := NewServerFromListener(&slowServer{}, ln, util.Options{Host: "127.0.0.1"})
+	defer second.Close()
+	if first.Port() == 0 || first.Port() == second.Port() {
+		t.Fatalf("ports %d and %d", first.Port(), second.Port())
+	}
+
+	served := make(chan error, 2)
+	go func() { served <- first.Serve() }()
+	go func() { served <- second.Serve() }()
+
+	check := func(s *Server) error {
+		proxy, err := client.NewClientProxy(util.Options{Host: "127.0.0.1", Port: s.Port(), Protocol: "tcp"})
+		if err != nil {
+			return err
+		}
+		defer proxy.Close()
+		return proxy.Invoke(&model.HealthCheckRequest{}, &model.HealthCheckResponse{})
+	}
+	if err := check(first); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != ErrServerClosed {
+		t.Fatalf("expected %v, got %v", ErrServerClosed, err)
+	}
+	if err := check(first); err == nil {
+		t.Fatal("shut down server still accepting")
+	}
+	if err := check(second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestServerSilentClient connects a client that never handshakes, which must
+// not hold up the next one.
+func TestServerSilentClient(t *testing.T) {
+	s, err := NewServer(&slowServer{}, util.Options{
+		Host: "127.0.0.1",
+		ConnLimits: &util.ConnLimits{
+			HandshakeTimeout: time.Minute,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Serve()
+
+	silent, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(s.Port()))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	proxy, err := client.NewClientProxy(util.Options{Host: "127.0.0.1", Port: s.Port(), Protocol: "tcp", DialTimeout: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	if err := proxy.Invoke(&model.HealthCheckRequest{}, &model.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	servers := make([]*Server, 2)
+	for i := range servers {
+		s, err := NewServer(&slowServer{}, util.Options{Host: "127.0.0.1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		if err := s.advertise(); err != nil {
+			t.Fatal(err)
+		}
+		go s.Serve()
+		servers[i] = s
+	}
+
+	uuid := reflect.TypeOf((*model.LookupRequest)(nil)).String()
+	check := func(s *Server, want model.HealthCheckResponse_ServingStatus) {
+		proxy, err := client.NewClientProxy(util.Options{Host: "127.0.0.1", Port: s.Port(), Protocol: "tcp"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer proxy.Close()
+
+		response := &model.HealthCheckResponse{}
+		if err := proxy.Invoke(&model.HealthCheckRequest{Service: uuid}, response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Status != want {
+			t.Fatalf("port %d: status = %v, want %v", s.Port(), response.Status, want)
+		}
+		for _, service := range s.services {
+			if service.Metadata[bonjour.MetadataHealth] != want.String() {
+				t.Fatalf("port %d: announced metadata %v", s.Port(), service.Metadata)
+			}
+		}
+	}
+
+	servers[0].SetServingStatus(uuid, NotServing)
+	check(servers[0], NotServing)
+	check(servers[1], Serving)
+}
+
+func TestServerLocalHealth(t *testing.T) {
+	s, err := NewServer(&slowServer{}, util.Options{Host: "health", Protocol: "mem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.advertise(); err != nil {
+		t.Fatal(err)
+	}
+
+	uuid := reflect.TypeOf((*model.LookupRequest)(nil)).String()
+	check := func(want model.HealthCheckResponse_ServingStatus) {
+		services, err := resolver.NewLocalResolver().Resolve(context.Background(), uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(services) != 1 || services[0].Metadata[bonjour.MetadataHealth] != want.String() {
+			t.Fatalf("registered %+v, want %v", services, want)
+		}
+	}
+
+	check(Serving)
+	s.SetServingStatus("", NotServing)
+	check(NotServing)
+}
+
+// failingListener fails every Accept as listeners out of descriptors do.
+type failingListener struct {
+	net.Listener
+	accepts int32
+}
+
+func (e *failingListener) Accept() (net.Conn, error) {
+	atomic.AddInt32(&e.accepts, 1)
+	return nil, &net.OpError{Op: "accept", Net: "tcp", Err: syscall.EMFILE}
+}
+
+func TestServerAcceptBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := &failingListener{Listener: ln}
+	s := NewServerFromListener(&slowServer{}, failing, util.Options{Host: "127.0.0.1"})
+	defer s.Close()
+	go s.Serve()
+
+	time.Sleep(200 * time.Millisecond)
+	if accepts := atomic.LoadInt32(&failing.accepts); accepts > 10 {
+		t.Fatalf("accepted %d times in 200ms", accepts)
+	}
+}
+
+func TestServerLocalTransports(t *testing.T) {
+	for _, options := range []util.Options{
+		{Host: "test", Protocol: "mem"},
+		{Host: t.TempDir() + "/middleair.sock", Protocol: "unix"},
+	} {
+		s, err := NewServer(&slowServer{started: make(chan struct{})}, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		served := make(chan error, 1)
+		go func() { served <- s.Serve() }()
+
+		uuid := reflect.TypeOf((*model.LookupRequest)(nil)).String()
+		for {
+			if _, err := resolver.NewLocalResolver().Resolve(context.Background(), uuid); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		c := client.NewClient(client.Config{})
+		res := &model.LookupResponse{}
+		if err := c.Invoke(&model.LookupRequest{}, res, nil); err != nil {
+			t.Fatal(options.Protocol, err)
+		}
+		if res.Revision != 1 {
+			t.Fatalf("%s: revision %d", options.Protocol, res.Revision)
+		}
+		c.Close()
+
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-served; err != ErrServerClosed {
+			t.Fatalf("expected %v, got %v", ErrServerClosed, err)
+		}
+	}
+}
+
+type countingServer struct {
+	calls chan string
+}
+
+func (e *countingServer) Registry() []*Service {
+	return []*Service{
+		&Service{
+			Interface: reflect.TypeOf((*model.LookupRequest)(nil)),
+			Handle: func(message proto.Message) (proto.Message, error) {
+				e.calls <- message.(*model.LookupRequest).Uuid
+				return &model.LookupResponse{Revision: 1}, nil
+			},
+		},
+	}
+}
+
+func (e *countingServer) Tags() []string {
+	return []string{}
+}
+
+func TestServerDatagrams(t *testing.T) {
+	for _, key := range [][]byte{[]byte("secret"), nil} {
+		sp := &countingServer{calls: make(chan string, 4)}
+		s, err := NewServer(sp, util.Options{Host: "127.0.0.1", Protocol: "udp", PreSharedKey: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		go s.Serve()
+
+		options := util.Options{Host: "127.0.0.1", Port: s.Port(), Protocol: "udp", PreSharedKey: key}
+		proxy, err := client.NewClientProxy(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key != nil {
+			options.PreSharedKey = []byte("guess")
+			forger, err := client.NewClientProxy(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := forger.Notify(&model.LookupRequest{Uuid: "forged"}); err != nil {
+				t.Fatal(err)
+			}
+			forger.Close()
+		}
+
+		if err := proxy.Notify(&model.LookupRequest{Uuid: "notified"}); err != nil {
+			t.Fatal(err)
+		}
+		if uuid := <-sp.calls; uuid != "notified" {
+			t.Fatalf("expected notified, got %s", uuid)
+		}
+
+		res := &model.LookupResponse{}
+		if err := proxy.Invoke(&model.LookupRequest{Uuid: "invoked"}, res); err != nil {
+			t.Fatal(err)
+		}
+		if uuid := <-sp.calls; uuid != "invoked" || res.Revision != 1 {
+			t.Fatalf("got %s at revision %d", uuid, res.Revision)
+		}
+
+		big := &model.LookupRequest{Uuid: strings.Repeat("x", util.DefaultMTU)}
+		if err := proxy.Invoke(big, res); err != util.ErrPayloadTooLarge {
+			t.Fatalf("expected %v, got %v", util.ErrPayloadTooLarge, err)
+		}
+
+		proxy.Close()
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case uuid := <-sp.calls:
+			t.Fatalf("unexpected call %s", uuid)
+		default:
+		}
+	}
+}
+
+// TestServerDatagramKeys checks that session keys only open datagrams on the
+// server whose listener took the handshake.
+func TestServerDatagramKeys(t *testing.T) {
+	servers := make([]*Server, 2)
+	calls := make([]chan string, 2)
+	for i := range servers {
+		calls[i] = make(chan string, 1)
+		s, err := NewServer(&countingServer{calls: calls[i]}, util.Options{Host: "127.0.0.1", Protocol: "udp"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		go s.Serve()
+		servers[i] = s
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(servers[0].Port()))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secureConn, err := crypto.NewSecureConn(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secureConn.Close()
+	if _, err := secureConn.WriteData([]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := secureConn.ReadData(); err != nil || data[0] != 200 {
+		t.Fatalf("handshake answered %v, %v", data, err)
+	}
+	c, err := secureConn.DatagramCipher(crypto.ToServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notify := func(s *Server, uuid string) {
+		message, err := util.SelfDescribingMessage(&model.LookupRequest{Uuid: uuid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		datagram, err := c.Seal(util.Datagram(util.OneWay, 1, message))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("udp", s.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write(datagram); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notify(servers[1], "elsewhere")
+	notify(servers[0], "here")
+	if uuid := <-calls[0]; uuid != "here" {
+		t.Fatalf("expected here, got %s", uuid)
+	}
+	select {
+	case uuid := <-calls[1]:
+		t.Fatalf("other server handled %s", uuid)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
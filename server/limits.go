@@ -0,0 +1,75 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+// Limits bound what the handlers of a server may use across all of its
+// invokers. Zero fields impose no limit.
+type Limits struct {
+	MaxConcurrentHandlers int
+	MaxRequestSize        int
+	HandlerTimeout        time.Duration
+
+	semaphore chan struct{}
+	once      sync.Once
+}
+
+// LimitedServerProxy is implemented by servers that want their handlers
+// bounded. Limits must return the same value on every call.
+type LimitedServerProxy interface {
+	ServerProxy
+	Limits() *Limits
+}
+
+func limitsOf(sp ServerProxy) *Limits {
+	if lsp, ok := sp.(LimitedServerProxy); ok {
+		return lsp.Limits()
+	}
+	return nil
+}
+
+func (e *Limits) allowsSize(size int) bool {
+	return e == nil || e.MaxRequestSize <= 0 || size <= e.MaxRequestSize
+}
+
+// maxSize is the largest request read off a connection.
+func (e *Limits) maxSize() int {
+	if e == nil || e.MaxRequestSize <= 0 {
+		return util.MaxFrameSize
+	}
+	return e.MaxRequestSize
+}
+
+func (e *Limits) acquire() bool {
+	if e == nil || e.MaxConcurrentHandlers <= 0 {
+		return true
+	}
+
+	e.once.Do(func() {
+		e.semaphore = make(chan struct{}, e.MaxConcurrentHandlers)
+	})
+	select {
+	case e.semaphore <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *Limits) release() {
+	if e == nil || e.MaxConcurrentHandlers <= 0 {
+		return
+	}
+	<-e.semaphore
+}
+
+func (e *Limits) timeout() time.Duration {
+	if e == nil {
+		return 0
+	}
+	return e.HandlerTimeout
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	model "github.com/t0rr3sp3dr0/middleair/proto"
+)
+
+// describedMessage is implemented by generated messages, whose descriptor is
+// the gzipped FileDescriptorProto they were declared in.
+type describedMessage interface {
+	proto.Message
+	Descriptor() ([]byte, []int)
+}
+
+func (e *Invoker) reflectionService() *Service {
+	return &Service{
+		Interface: reflect.TypeOf((*model.ServerReflectionRequest)(nil)),
+		Handle:    e.describe,
+		Response:  reflect.TypeOf((*model.ServerReflectionResponse)(nil)),
+	}
+}
+
+// describe lists the services of the server, or only the one asked for,
+// along with the file descriptors declaring their messages. Descriptors come
+// after those they import, so they can be loaded in order.
+func (e *Invoker) describe(message proto.Message) (proto.Message, error) {
+	request := message.(*model.ServerReflectionRequest)
+
+	response := &model.ServerReflectionResponse{}
+	files := &fileSet{
+		seen: make(map[string]bool),
+	}
+	tags := e.sp.Tags()
+	for _, service := range e.registry() {
+		uuid := service.Interface.String()
+		if request.Uuid != "" && request.Uuid != uuid {
+			continue
+		}
+
+		reflected := &model.ReflectedService{
+			Uuid: uuid,
+			Tags: tags,
+		}
+		for _, t := range []reflect.Type{service.Interface, service.Response} {
+			if t == nil {
+				continue
+			}
+
+			name, err := files.addMessage(t)
+			if err != nil {
+				return nil, err
+			}
+			if t == service.Interface {
+				reflected.RequestType = name
+			} else {
+				reflected.ResponseType = name
+			}
+		}
+		response.Services = append(response.Services, reflected)
+	}
+	response.FileDescriptors = files.descriptors
+	return response, nil
+}
+
+type fileSet struct {
+	seen        map[string]bool
+	descriptors [][]byte
+}
+
+func (e *fileSet) addMessage(t reflect.Type) (string, error) {
+	if t.Kind() != reflect.Ptr {
+		t = reflect.PtrTo(t)
+	}
+	message, ok := reflect.New(t.Elem()).Interface().(describedMessage)
+	if !ok {
+		return "", fmt.Errorf("No Descriptor: %v", t)
+	}
+
+	data, _ := message.Descriptor()
+	fd, err := decodeFileDescriptor(data)
+	if err != nil {
+		return "", err
+	}
+	if err := e.addFile(fd); err != nil {
+		return "", err
+	}
+	return proto.MessageName(message), nil
+}
+
+func (e *fileSet) addFile(fd *descriptor.FileDescriptorProto) error {
+	if e.seen[fd.GetName()] {
+		return nil
+	}
+	e.seen[fd.GetName()] = true
+
+	for _, dependency := range fd.Dependency {
+		data := proto.FileDescriptor(dependency)
+		if data == nil {
+			return fmt.Errorf("Unknown File: %v", dependency)
+		}
+		dfd, err := decodeFileDescriptor(data)
+		if err != nil {
+			return err
+		}
+		if err := e.addFile(dfd); err != nil {
+			return err
+		}
+	}
+
+	data, err := proto.Marshal(fd)
+	if err != nil {
+		return err
+	}
+	e.descriptors = append(e.descriptors, data)
+	return nil
+}
+
+func decodeFileDescriptor(data []byte) (*descriptor.FileDescriptorProto, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(b, fd); err != nil {
+		return nil, err
+	}
+	return fd, nil
+}
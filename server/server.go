@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/t0rr3sp3dr0/middleair/bonjour"
+	"github.com/t0rr3sp3dr0/middleair/crypto"
+	"github.com/t0rr3sp3dr0/middleair/util"
+)
+
+var (
+	ErrServerClosed = errors.New("Server Closed")
+)
+
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// Server accepts connections for a ServerProxy on a listener of its own,
+// announcing the registry while serving, or registering it for local
+// discovery off tcp. Unlike invokers made one by one,
+// servers share nothing keyed by port.
+type Server struct {
+	sp       ServerProxy
+	options  util.Options
+	listener *sharedListener
+	services []*bonjour.Service
+	health   *health
+	invokers map[*Invoker]struct{}
+	closed   bool
+	mutex    *sync.Mutex
+
+	// Servers on udp read datagrams from packetConn, handling them with
+	// invoker, and have no listener if keyed beforehand.
+	packetConn net.PacketConn
+	keyring    *crypto.Keyring
+	datagrams  *sync.WaitGroup
+	handlers   chan struct{}
+	invoker    *Invoker
+}
+
+// NewServer listens as options say, on any port if theirs is zero, which
+// Port reports. Protocol defaults to tcp.
+func NewServer(sp ServerProxy, options util.Options) (*Server, error) {
+	if options.Protocol == "" {
+		options.Protocol = "tcp"
+	}
+	if options.Protocol == "udp" {
+		return newDatagramServer(sp, options)
+	}
+	ln, err := util.Listen(options)
+	if err != nil {
+		return nil, err
+	}
+	return NewServerFromListener(sp, ln, options), nil
+}
+
+// NewServerFromListener serves on ln, which the server closes when done.
+// The protocol and address of options are replaced by those of ln.
+func NewServerFromListener(sp ServerProxy, ln net.Listener, options util.Options) *Server {
+	options.Protocol = ln.Addr().Network()
+	if addr, ok := ln.Addr().(*net.TCPAddr); ok {
+		options.Port = uint16(addr.Port)
+	} else {
+		options.Host = ln.Addr().String()
+	}
+
+	listener := newSharedListener(ln, ln.Addr().String(), options.ConnLimits)
+	listener.refs++
+
+	return &Server{
+		sp:       sp,
+		options:  options,
+		listener: listener,
+		health:   newHealth(),
+		invokers: make(map[*Invoker]struct{}),
+		mutex:    &sync.Mutex{},
+	}
+}
+
+func (e *Server) Addr() net.Addr {
+	if e.packetConn != nil {
+		return e.packetConn.LocalAddr()
+	}
+	return e.listener.Addr()
+}
+
+func (e *Server) Port() uint16 {
+	return e.options.Port
+}
+
+// Serve announces the registry and handles connections until the server is
+// shut down or closed, returning ErrServerClosed then.
+func (e *Server) Serve() error {
+	if err := e.advertise(); err != nil {
+		return err
+	}
+
+	if e.packetConn != nil {
+		if e.listener == nil {
+			return e.serveDatagrams()
+		}
+		go func() {
+			if err := e.serveDatagrams(); err != ErrServerClosed {
+				if loggingLevel&LogEnabled != LogDisabled {
+					logger.Println(err)
+				}
+			}
+		}()
+	}
+
+	// Connections are handshaken in goroutines of their own, so that slow
+	// clients hold up nobody else. Failures of the listener are retried after
+	// a delay growing as they repeat.
+	var delay time.Duration
+	for {
+		conn, err := e.listener.Accept()
+		if err != nil {
+			if e.stopped() {
+				return ErrServerClosed
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(err)
+			}
+
+			if delay == 0 {
+				delay = minAcceptDelay
+			} else if delay *= 2; delay > maxAcceptDelay {
+				delay = maxAcceptDelay
+			}
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+
+		invoker, err := e.newInvoker()
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		go e.serveConn(invoker, conn)
+	}
+}
+
+func (e *Server) serveConn(invoker *Invoker, conn net.Conn) {
+	defer e.untrack(invoker)
+
+	if err := invoker.srh.acceptConn(conn, e.options.Credentials); err != nil {
+		if err != errRejected && loggingLevel&LogEnabled != LogDisabled {
+			logger.Println(err)
+		}
+		if err := invoker.srh.abandon(); err != nil {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(err)
+			}
+		}
+		return
+	}
+
+	if err := invoker.Loop(); err != nil {
+		if loggingLevel&LogEnabled != LogDisabled {
+			logger.Println(err)
+		}
+	}
+}
+
+// Shutdown withdraws the registry, stops accepting and shuts down every
+// connection as Invoker.Shutdown does, all within ctx. Datagrams being
+// handled are given as long.
+func (e *Server) Shutdown(ctx context.Context) error {
+	invokers := e.stop()
+
+	errs := make(chan error, len(invokers)+1)
+	if e.datagrams != nil {
+		go func() {
+			done := make(chan struct{})
+			go func() {
+				e.datagrams.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				errs <- nil
+			case <-ctx.Done():
+				errs <- ctx.Err()
+			}
+		}()
+	}
+	for _, invoker := range invokers {
+		go func(invoker *Invoker) {
+			errs <- invoker.Shutdown(ctx)
+		}(invoker)
+	}
+
+	var err error
+	for range invokers {
+		if ierr := <-errs; ierr != nil && err == nil {
+			err = ierr
+		}
+	}
+	if e.datagrams != nil {
+		if derr := <-errs; derr != nil && err == nil {
+			err = derr
+		}
+	}
+	return err
+}
+
+// Close withdraws the registry and closes the listener and every connection
+// at once.
+func (e *Server) Close() error {
+	var err error
+	for _, invoker := range e.stop() {
+		if ierr := invoker.Close(); ierr != nil && err == nil {
+			err = ierr
+		}
+	}
+	return err
+}
+
+// stop marks the server closed, withdraws its registry and closes its
+// listener, returning the invokers left to stop.
+func (e *Server) stop() []*Invoker {
+	e.mutex.Lock()
+	e.closed = true
+	services := e.services
+	e.services = nil
+	invokers := make([]*Invoker, 0, len(e.invokers))
+	for invoker := range e.invokers {
+		invokers = append(invokers, invoker)
+	}
+	e.mutex.Unlock()
+
+	e.health.withdrawAll(services)
+	if e.packetConn != nil {
+		if err := e.packetConn.Close(); err != nil {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(err)
+			}
+		}
+	}
+	if e.listener != nil {
+		if err := e.listener.release(true); err != nil {
+			if loggingLevel&LogEnabled != LogDisabled {
+				logger.Println(err)
+			}
+		}
+	}
+	return invokers
+}
+
+func (e *Server) advertise() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return ErrServerClosed
+	}
+	if e.services != nil {
+		return nil
+	}
+
+	services, err := e.health.advertiseAll(e.sp, e.options)
+	if err != nil {
+		return err
+	}
+	e.services = services
+	return nil
+}
+
+func (e *Server) newInvoker() (*Invoker, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed || !e.listener.acquire() {
+		return nil, ErrServerClosed
+	}
+
+	// Handshakes for udp come over tcp.
+	options := e.options
+	options.Protocol = e.listener.Addr().Network()
+
+	invoker := newInvoker(e.sp, newServerRequestHandler(options, e.listener), nil, e.health)
+	e.invokers[invoker] = struct{}{}
+	return invoker, nil
+}
+
+func (e *Server) untrack(invoker *Invoker) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	delete(e.invokers, invoker)
+}
+
+func (e *Server) stopped() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.closed
+}
@@ -11,9 +11,12 @@ type HandleFn func(proto.Message) (proto.Message, error)
 type Service struct {
 	Interface reflect.Type
 	Handle    HandleFn
+	// Response is the type Handle answers with, published through
+	// reflection when set.
+	Response reflect.Type
 }
 
 type ServerProxy interface {
 	Registry() []*Service
-	Tags() [12]string
+	Tags() []string
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/t0rr3sp3dr0/middleair/crypto"
@@ -13,63 +14,124 @@ import (
 )
 
 var (
-	listeners                = make(map[uint16]net.Listener)
-	listenersMutex           = &sync.RWMutex{}
-	listenersWaitGroups      = make(map[uint16]*sync.WaitGroup)
-	listenersWaitGroupsMutex = &sync.RWMutex{}
+	listeners      = make(map[string]*sharedListener)
+	listenersMutex = &sync.Mutex{}
+
+	errRejected = fmt.Errorf("Over Capacity")
 )
 
+// Handlers on the same address share one listener, which is closed once the
+// last of them is.
+type sharedListener struct {
+	net.Listener
+	key    string
+	refs   int
+	closed bool
+
+	limits     *util.ConnLimits
+	conns      int
+	handshakes int
+	rejects    int
+	buckets    map[string]*bucket
+	mutex      *sync.Mutex
+
+	// keyring takes the session keys of connections accepted, for the
+	// datagrams of their clients to open, on listeners of udp servers.
+	keyring *crypto.Keyring
+}
+
 type ServerRequestHandler struct {
 	options  util.Options
-	listener net.Listener
-	netConn  crypto.SecureConn
+	listener *sharedListener
+	// conn is the connection being handshaken, closed along with the
+	// handler.
+	conn    net.Conn
+	netConn crypto.SecureConn
+	session *crypto.DatagramCipher
+	closed  bool
+	mutex   *sync.Mutex
 }
 
 func NewServerRequestHandler(options util.Options) (*ServerRequestHandler, error) {
-	e := &ServerRequestHandler{
-		options: options,
+	listener, err := acquireListener(options)
+	if err != nil {
+		return nil, err
 	}
 
+	return newServerRequestHandler(options, listener), nil
+}
+
+// newServerRequestHandler accepts on listener, which it must hold a
+// reference to.
+func newServerRequestHandler(options util.Options, listener *sharedListener) *ServerRequestHandler {
+	return &ServerRequestHandler{
+		options:  options,
+		listener: listener,
+		mutex:    &sync.Mutex{},
+	}
+}
+
+func acquireListener(options util.Options) (*sharedListener, error) {
 	listenersMutex.Lock()
-	listener, ok := listeners[e.options.Port]
+	defer listenersMutex.Unlock()
+
+	// Handlers of any host share the wildcard tcp listener of their port.
+	if options.Protocol == "tcp" {
+		options.Host = ""
+	}
+	key := fmt.Sprintf("%s:%s:%d", options.Protocol, options.Host, options.Port)
+
+	listener, ok := listeners[key]
 	if !ok {
-		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", e.options.Port))
+		ln, err := util.Listen(options)
 		if err != nil {
 			return nil, err
 		}
-		listener = ln
-		listeners[e.options.Port] = listener
-
-		waitGroup := &sync.WaitGroup{}
-		waitGroup.Add(1)
-		defer waitGroup.Done()
-		listenersWaitGroupsMutex.Lock()
-		listenersWaitGroups[e.options.Port] = waitGroup
-		listenersWaitGroupsMutex.Unlock()
-		go func() {
-			waitGroup.Wait()
-
-			listenersMutex.Lock()
-			delete(listeners, e.options.Port)
-			listenersMutex.Unlock()
-
-			listenersWaitGroupsMutex.Lock()
-			delete(listenersWaitGroups, e.options.Port)
-			listenersWaitGroupsMutex.Unlock()
-
-			if err := e.listener.Close(); err != nil {
-				panic(err)
-			}
-		}()
+		listener = newSharedListener(ln, key, options.ConnLimits)
+		listeners[key] = listener
+	}
+	listener.refs++
+	return listener, nil
+}
+
+func newSharedListener(ln net.Listener, key string, limits *util.ConnLimits) *sharedListener {
+	return &sharedListener{
+		Listener: ln,
+		key:      key,
+		limits:   limits,
+		buckets:  make(map[string]*bucket),
+		mutex:    &sync.Mutex{},
 	}
-	listenersMutex.Unlock()
-	e.listener = listener
+}
+
+// acquire adds a reference to a listener already held.
+func (e *sharedListener) acquire() bool {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	if e.closed {
+		return false
+	}
+	e.refs++
+	return true
+}
 
-	listenersWaitGroupsMutex.RLock()
-	listenersWaitGroups[e.options.Port].Add(1)
-	listenersWaitGroupsMutex.RUnlock()
+// release drops a reference to the listener. With force it is closed even if
+// other handlers still hold it, failing their pending accepts.
+func (e *sharedListener) release(force bool) error {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	e.refs--
+	if e.closed || (e.refs > 0 && !force) {
+		return nil
+	}
 
-	return e, nil
+	e.closed = true
+	if listeners[e.key] == e {
+		delete(listeners, e.key)
+	}
+	return e.Listener.Close()
 }
 
 func (e *ServerRequestHandler) Accept(credentials []byte) error {
@@ -77,23 +139,96 @@ func (e *ServerRequestHandler) Accept(credentials []byte) error {
 		credentials = []byte{}
 	}
 
+	e.mutex.Lock()
 	if e.netConn.Conn != nil {
+		e.mutex.Unlock()
 		return fmt.Errorf("Already Accepted")
 	}
+	if e.closed {
+		e.mutex.Unlock()
+		return fmt.Errorf("Already Closed")
+	}
+	e.mutex.Unlock()
+
+	// Connections over capacity are turned away without returning.
+	for {
+		conn, err := e.listener.Accept()
+		if err != nil {
+			return err
+		}
+		if err := e.acceptConn(conn, credentials); err != errRejected {
+			return err
+		}
+	}
+}
+
+// acceptConn admits conn, already accepted from the listener, and secures
+// it. Connections over capacity are rejected with errRejected.
+func (e *ServerRequestHandler) acceptConn(conn net.Conn, credentials []byte) error {
+	if credentials == nil {
+		credentials = []byte{}
+	}
+	if !e.listener.admit(conn) {
+		e.listener.reject(conn)
+		return errRejected
+	}
+
+	e.mutex.Lock()
+	if e.closed {
+		e.mutex.Unlock()
+		conn.Close()
+		e.listener.handshaken()
+		e.listener.disconnected()
+		return fmt.Errorf("Already Closed")
+	}
+	e.conn = conn
+	e.mutex.Unlock()
+
+	secureConn, err := e.handshake(conn, credentials)
+	e.listener.handshaken()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.conn = nil
+	if err != nil {
+		e.listener.disconnected()
+		return err
+	}
+	if e.closed {
+		secureConn.Close()
+		e.listener.disconnected()
+		return fmt.Errorf("Already Closed")
+	}
+	e.netConn = *secureConn
 
-	conn, err := e.listener.Accept()
+	if e.listener.keyring == nil {
+		return nil
+	}
+	session, err := secureConn.DatagramCipher(crypto.ToClient)
 	if err != nil {
 		return err
 	}
+	e.session = session
+	e.listener.keyring.Add(session)
+	return nil
+}
+
+// handshake secures conn and checks the credentials it presents, closing it
+// on failure.
+func (e *ServerRequestHandler) handshake(conn net.Conn, credentials []byte) (*crypto.SecureConn, error) {
+	conn.SetDeadline(time.Now().Add(e.listener.handshakeTimeout()))
+
 	secureConn, err := crypto.NewSecureConn(conn)
 	if err != nil {
-		return err
+		conn.Close()
+		return nil, err
 	}
 
 	data, err := secureConn.ReadData()
 	if err != nil {
 		defer secureConn.Close()
-		return err
+		return nil, err
 	}
 
 	res := []byte{200}
@@ -103,55 +238,87 @@ func (e *ServerRequestHandler) Accept(credentials []byte) error {
 
 	if _, err := secureConn.WriteData(res); err != nil {
 		defer secureConn.Close()
-		return err
+		return nil, err
 	}
 
 	if res[0] == 200 {
-		e.netConn = *secureConn
-		return nil
+		conn.SetDeadline(time.Time{})
+		return secureConn, nil
 	}
 
 	defer secureConn.Close()
 	switch res[0] {
 	case 401 % 256:
-		return util.ErrUnauthorized
+		return nil, util.ErrUnauthorized
 
 	case 403 % 256:
-		return util.ErrForbidden
+		return nil, util.ErrForbidden
 
 	default:
-		return util.ErrUnknown
+		return nil, util.ErrUnknown
 	}
 }
 
+// abandon closes a handler whose connection failed to be accepted, leaving
+// the listener to others.
+func (e *ServerRequestHandler) abandon() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.listener.release(false)
+}
+
+// Close releases the listener and closes the connection, if any. Closing a
+// handler that has not accepted yet stops the port from accepting at all.
 func (e *ServerRequestHandler) Close() error {
-	if e.netConn.Conn == nil {
-		return fmt.Errorf("Not Accepted")
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	if e.session != nil {
+		e.listener.keyring.Remove(e.session)
+	}
+	if e.conn != nil {
+		e.conn.Close()
 	}
 
 	switch e.options.Protocol {
-	case "tcp":
-		if err := e.netConn.Close(); err != nil {
-			return err
+	case "tcp", "unix", "mem":
+		err := e.listener.release(e.netConn.Conn == nil)
+		if e.netConn.Conn != nil {
+			e.listener.disconnected()
+			if cerr := e.netConn.Close(); cerr != nil {
+				err = cerr
+			}
 		}
-		listenersWaitGroupsMutex.RLock()
-		listenersWaitGroups[e.options.Port].Done()
-		listenersWaitGroupsMutex.RUnlock()
-		return nil
+		return err
 
 	default:
 		return util.ErrMethodNotAllowed
 	}
 }
 
-func (e *ServerRequestHandler) Receive() ([]byte, error) {
+// Receive reads a request of at most maxSize bytes, answering larger ones
+// with 413 without reading them into memory.
+func (e *ServerRequestHandler) Receive(maxSize int) ([]byte, error) {
 	if e.netConn.Conn == nil {
 		return nil, fmt.Errorf("Not Accepted")
 	}
 
 	switch e.options.Protocol {
-	case "tcp":
-		return e.netConn.ReadData()
+	case "tcp", "unix", "mem":
+		if d := e.listener.idleTimeout(); d > 0 {
+			e.netConn.SetReadDeadline(time.Now().Add(d))
+		}
+		return e.netConn.ReadDataLimit(maxSize)
 
 	default:
 		return nil, util.ErrMethodNotAllowed
@@ -164,7 +331,7 @@ func (e *ServerRequestHandler) Send(message []byte) error {
 	}
 
 	switch e.options.Protocol {
-	case "tcp":
+	case "tcp", "unix", "mem":
 		_, err := e.netConn.WriteData(message)
 		return err
 
@@ -173,38 +340,26 @@ func (e *ServerRequestHandler) Send(message []byte) error {
 	}
 }
 
-func (e *ServerRequestHandler) handleBadRequest(err error) {
-	er := &model.ErrorResponse{
-		Error: &model.Error{
-			Code:    400,
-			Message: err.Error(),
-		},
-	}
+func (e *ServerRequestHandler) handleBadRequest(err error) error {
+	return e.handleError(400, err)
+}
 
-	data, err := proto.Marshal(er)
+func (e *ServerRequestHandler) handleError(code uint64, err error) error {
+	data, err := errorResponse(code, err)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	if err := e.Send(data); err != nil {
-		panic(err)
-	}
+	return e.Send(data)
 }
 
-func (e *ServerRequestHandler) handleInternalServerError(err error) {
+func errorResponse(code uint64, err error) ([]byte, error) {
 	er := &model.ErrorResponse{
 		Error: &model.Error{
-			Code:    500,
+			Code:    code,
 			Message: err.Error(),
 		},
 	}
 
-	data, err := proto.Marshal(er)
-	if err != nil {
-		panic(err)
-	}
-
-	if err := e.Send(data); err != nil {
-		panic(err)
-	}
+	return proto.Marshal(er)
 }
@@ -2,7 +2,9 @@ package util
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	model "github.com/t0rr3sp3dr0/middleair/proto"
@@ -17,6 +19,57 @@ var (
 	ErrPayloadTooLarge    = errors.New("413 - Payload Too Large")
 	ErrExpectationFailed  = errors.New("417 - Expectation Failed")
 	ErrServiceUnavailable = errors.New("503 - Service Unavailable")
+	ErrGatewayTimeout     = errors.New("504 - Gateway Timeout")
+
+	codedErrors = []error{
+		ErrUnauthorized,
+		ErrForbidden,
+		ErrNotFound,
+		ErrMethodNotAllowed,
+		ErrPayloadTooLarge,
+		ErrExpectationFailed,
+		ErrServiceUnavailable,
+		ErrGatewayTimeout,
+	}
+)
+
+// Error is an error response received from a provider.
+type Error struct {
+	Code    uint64
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// Code returns the status code of err, or 0 if it has none. Handlers that
+// fail with one of the errors above are answered with 500, so the code of
+// the message wins over the one of the response.
+func Code(err error) uint64 {
+	message := err.Error()
+	if e, ok := err.(*Error); ok {
+		message = e.Message
+	}
+
+	for _, codedError := range codedErrors {
+		if message == codedError.Error() {
+			var code uint64
+			fmt.Sscanf(message, "%d", &code)
+			return code
+		}
+	}
+
+	if e, ok := err.(*Error); ok {
+		return e.Code
+	}
+	return 0
+}
+
+const (
+	// DefaultMTU is the largest datagram sent or accepted over udp unless
+	// options say otherwise, the UDP payload that fits the minimum IPv6 MTU.
+	DefaultMTU = 1232
 )
 
 type Options struct {
@@ -24,6 +77,31 @@ type Options struct {
 	Port        uint16
 	Protocol    string
 	Credentials []byte
+	// PreSharedKey authenticates udp datagrams in place of a handshake over
+	// tcp. Servers given one take no handshakes for their datagrams.
+	PreSharedKey []byte
+	// MTU bounds udp datagrams, defaulting to DefaultMTU.
+	MTU int
+	// DialTimeout bounds dialing, and the handshake after it, on clients.
+	// Zero imposes no limit.
+	DialTimeout time.Duration
+	// ConnLimits apply to servers only, and to every handler on the port
+	// once set by the first one to listen on it.
+	ConnLimits *ConnLimits
+}
+
+// ConnLimits bound the connections a server accepts. Connections over them
+// are answered with 503 before any key is generated. Zero fields impose no
+// limit, but for HandshakeTimeout, which defaults to ten seconds.
+type ConnLimits struct {
+	MaxConnections   int
+	MaxHandshakes    int
+	HandshakeTimeout time.Duration
+	IdleTimeout      time.Duration
+	// New connections allowed per second from each IP, in bursts of up to
+	// BurstPerIP, which defaults to one.
+	RatePerIP  float64
+	BurstPerIP int
 }
 
 func SelfDescribingMessage(message proto.Message) ([]byte, error) {
@@ -0,0 +1,324 @@
+package util
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// OneWay marks datagrams whose request is not to be answered.
+	OneWay byte = 1 << iota
+)
+
+const (
+	datagramHeaderSize = 1 + 8
+
+	memBacklog = 128
+	// Writers block once this much is written to a pipe and not yet read.
+	memBufferSize = 256 << 10
+	// Closed pipes give up on writes the other end does not read by then.
+	memLinger = 5 * time.Second
+)
+
+var (
+	memListeners      = make(map[string]*memListener)
+	memListenersMutex = &sync.Mutex{}
+)
+
+// Listen listens as options describe: tcp on Host and Port, unix on the
+// socket at Host, or mem on the in-process pipe named Host.
+func Listen(options Options) (net.Listener, error) {
+	switch options.Protocol {
+	case "tcp":
+		return net.Listen("tcp", net.JoinHostPort(options.Host, strconv.Itoa(int(options.Port))))
+
+	case "unix":
+		return net.Listen("unix", options.Host)
+
+	case "mem":
+		return listenMem(options.Host)
+
+	default:
+		return nil, ErrMethodNotAllowed
+	}
+}
+
+// ListenPacket listens for udp datagrams on Host and Port.
+func ListenPacket(options Options) (net.PacketConn, error) {
+	if options.Protocol != "udp" {
+		return nil, ErrMethodNotAllowed
+	}
+	return net.ListenPacket("udp", net.JoinHostPort(options.Host, strconv.Itoa(int(options.Port))))
+}
+
+// Dial connects to what Listen listens on for the same options.
+func Dial(options Options) (net.Conn, error) {
+	switch options.Protocol {
+	case "tcp", "udp":
+		return net.DialTimeout(options.Protocol, net.JoinHostPort(options.Host, strconv.Itoa(int(options.Port))), options.DialTimeout)
+
+	case "unix":
+		return net.DialTimeout("unix", options.Host, options.DialTimeout)
+
+	case "mem":
+		return dialMem(options.Host, options.DialTimeout)
+
+	default:
+		return nil, ErrMethodNotAllowed
+	}
+}
+
+// Datagram frames a udp request or response: flags, then the ID pairing
+// responses with their request, then the message.
+func Datagram(flags byte, id uint64, message []byte) []byte {
+	data := make([]byte, datagramHeaderSize, datagramHeaderSize+len(message))
+	data[0] = flags
+	binary.LittleEndian.PutUint64(data[1:], id)
+	return append(data, message...)
+}
+
+func ParseDatagram(data []byte) (byte, uint64, []byte, error) {
+	if len(data) < datagramHeaderSize {
+		return 0, 0, nil, ErrExpectationFailed
+	}
+	return data[0], binary.LittleEndian.Uint64(data[1:]), data[datagramHeaderSize:], nil
+}
+
+type memAddr string
+
+func (e memAddr) Network() string {
+	return "mem"
+}
+
+func (e memAddr) String() string {
+	return string(e)
+}
+
+type memListener struct {
+	name  string
+	conns chan net.Conn
+	done  chan struct{}
+	once  *sync.Once
+}
+
+func listenMem(name string) (net.Listener, error) {
+	memListenersMutex.Lock()
+	defer memListenersMutex.Unlock()
+
+	if _, ok := memListeners[name]; ok {
+		return nil, &net.OpError{Op: "listen", Net: "mem", Addr: memAddr(name), Err: syscall.EADDRINUSE}
+	}
+
+	e := &memListener{
+		name:  name,
+		conns: make(chan net.Conn, memBacklog),
+		done:  make(chan struct{}),
+		once:  &sync.Once{},
+	}
+	memListeners[name] = e
+	return e, nil
+}
+
+func dialMem(name string, timeout time.Duration) (net.Conn, error) {
+	memListenersMutex.Lock()
+	e, ok := memListeners[name]
+	memListenersMutex.Unlock()
+
+	refused := &net.OpError{Op: "dial", Net: "mem", Addr: memAddr(name), Err: syscall.ECONNREFUSED}
+	if !ok {
+		return nil, refused
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	local, remote := net.Pipe()
+	conn := newPipeConn(remote)
+	select {
+	case e.conns <- conn:
+		return newPipeConn(local), nil
+
+	case <-e.done:
+		local.Close()
+		conn.Close()
+		return nil, refused
+
+	case <-expired:
+		local.Close()
+		conn.Close()
+		return nil, &net.OpError{Op: "dial", Net: "mem", Addr: memAddr(name), Err: os.ErrDeadlineExceeded}
+	}
+}
+
+func (e *memListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-e.conns:
+		return conn, nil
+
+	case <-e.done:
+		return nil, &net.OpError{Op: "accept", Net: "mem", Addr: memAddr(e.name), Err: net.ErrClosed}
+	}
+}
+
+func (e *memListener) Close() error {
+	e.once.Do(func() {
+		memListenersMutex.Lock()
+		delete(memListeners, e.name)
+		memListenersMutex.Unlock()
+
+		close(e.done)
+	})
+	return nil
+}
+
+func (e *memListener) Addr() net.Addr {
+	return memAddr(e.name)
+}
+
+// pipeConn buffers writes as sockets do, so that both ends of a net.Pipe,
+// which blocks writers until read, may write at once. Writers block once
+// memBufferSize is buffered, until their write deadline.
+type pipeConn struct {
+	net.Conn
+	pending       [][]byte
+	size          int
+	writeDeadline time.Time
+	closed        bool
+	err           error
+	cond          *sync.Cond
+}
+
+func newPipeConn(conn net.Conn) *pipeConn {
+	e := &pipeConn{
+		Conn: conn,
+		cond: sync.NewCond(&sync.Mutex{}),
+	}
+	go e.flush()
+	return e
+}
+
+func (e *pipeConn) Write(b []byte) (int, error) {
+	e.cond.L.Lock()
+	defer e.cond.L.Unlock()
+
+	for {
+		if e.closed {
+			return 0, io.ErrClosedPipe
+		}
+		if e.err != nil {
+			return 0, e.err
+		}
+		if !e.writeDeadline.IsZero() && !time.Now().Before(e.writeDeadline) {
+			return 0, &net.OpError{Op: "write", Net: "mem", Err: os.ErrDeadlineExceeded}
+		}
+		// Writes larger than the buffer go through once it is empty.
+		if e.size == 0 || e.size+len(b) <= memBufferSize {
+			break
+		}
+		e.cond.Wait()
+	}
+
+	e.pending = append(e.pending, append([]byte{}, b...))
+	e.size += len(b)
+	e.cond.Broadcast()
+	return len(b), nil
+}
+
+func (e *pipeConn) SetDeadline(t time.Time) error {
+	if err := e.Conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return e.SetWriteDeadline(t)
+}
+
+// SetWriteDeadline applies to writers blocked on a full buffer, not to what
+// is buffered already.
+func (e *pipeConn) SetWriteDeadline(t time.Time) error {
+	e.cond.L.Lock()
+	defer e.cond.L.Unlock()
+
+	if e.closed {
+		return io.ErrClosedPipe
+	}
+	e.writeDeadline = t
+	e.cond.Broadcast()
+	if !t.IsZero() {
+		time.AfterFunc(time.Until(t), func() {
+			e.cond.L.Lock()
+			e.cond.Broadcast()
+			e.cond.L.Unlock()
+		})
+	}
+	return nil
+}
+
+func (e *pipeConn) Read(b []byte) (int, error) {
+	n, err := e.Conn.Read(b)
+	if err != nil {
+		e.cond.L.Lock()
+		if e.closed {
+			err = net.ErrClosed
+		}
+		e.cond.L.Unlock()
+	}
+	return n, err
+}
+
+// Close fails reads at once but lets pending writes through before closing
+// the pipe.
+func (e *pipeConn) Close() error {
+	e.cond.L.Lock()
+	defer e.cond.L.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	e.Conn.SetReadDeadline(time.Now())
+	e.Conn.SetWriteDeadline(time.Now().Add(memLinger))
+	e.cond.Broadcast()
+	return nil
+}
+
+func (e *pipeConn) flush() {
+	defer e.Conn.Close()
+
+	for {
+		e.cond.L.Lock()
+		for len(e.pending) == 0 && !e.closed {
+			e.cond.Wait()
+		}
+		if len(e.pending) == 0 {
+			e.cond.L.Unlock()
+			return
+		}
+		b := e.pending[0]
+		e.pending = e.pending[1:]
+		e.cond.L.Unlock()
+
+		_, err := e.Conn.Write(b)
+
+		e.cond.L.Lock()
+		e.size -= len(b)
+		if err != nil {
+			e.err = err
+			e.pending = nil
+			e.size = 0
+		}
+		e.cond.Broadcast()
+		e.cond.L.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
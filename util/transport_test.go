@@ -0,0 +1,59 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemBackpressure(t *testing.T) {
+	ln, err := Listen(Options{Host: "backpressure", Protocol: "mem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conn, err := Dial(Options{Host: "backpressure", Protocol: "mem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	peer, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	// Nothing reads, so writes stop once the buffer is full.
+	conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	chunk := make([]byte, 64<<10)
+	written := 0
+	for {
+		n, err := conn.Write(chunk)
+		written += n
+		if err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Fatal(err)
+			}
+			break
+		}
+		if written > 4*memBufferSize {
+			t.Fatalf("wrote %d bytes nobody read", written)
+		}
+	}
+
+	conn.SetWriteDeadline(time.Time{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(chunk)
+		done <- err
+	}()
+	if _, err := io.ReadFull(peer, make([]byte, written+len(chunk))); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,23 +2,50 @@ package util
 
 import (
 	"encoding/binary"
-	"math"
+	"io"
 	"net"
 )
 
+const (
+	MaxFrameSize = 16 << 20
+)
+
 type WrapperConn struct {
 	net.Conn
 }
 
-func (e *WrapperConn) ReadData() ([]byte, error) {
-	buf := make([]byte, math.MaxInt16)
-	n, err := e.Read(buf)
-	if n < 8 || err != nil {
+func ReadFrame(r io.Reader) ([]byte, error) {
+	return ReadFrameLimit(r, MaxFrameSize)
+}
+
+// ReadFrameLimit reads a frame of at most max bytes. Larger ones, up to
+// MaxFrameSize, are skipped whole, so the next frame can still be read.
+func ReadFrameLimit(r io.Reader, max int) ([]byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
 		return nil, err
 	}
-	size := int(binary.LittleEndian.Uint64(buf[:8]))
 
-	return buf[8 : size+8], nil
+	size := binary.LittleEndian.Uint64(header)
+	if size > MaxFrameSize {
+		return nil, ErrPayloadTooLarge
+	}
+	if size > uint64(max) {
+		if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+			return nil, err
+		}
+		return nil, ErrPayloadTooLarge
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (e *WrapperConn) ReadData() ([]byte, error) {
+	return ReadFrame(e.Conn)
 }
 
 func (e *WrapperConn) WriteData(data []byte) (int, error) {